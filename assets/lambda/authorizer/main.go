@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"pattoken"
+)
+
+const tableNameEnvVar = string("PAT_TABLE_NAME")
+const tableNameDefault = string("xaas-api-pats")
+
+// PATs created before they could expire are given the longest lifetime that
+// the pats Lambda hands out, when they're next checked.
+const patLifetimeDaysMaxEnvVar = string("PAT_LIFETIME_DAYS_MAX")
+const patLifetimeDaysMaxDefault = int(90)
+
+// When each PAT was last used is recorded at most once per lastUsedInterval,
+// so that checking a PAT isn't a write every time.
+const lastUsedInterval = 5 * time.Minute
+
+// API Gateway turns this error into a `401`.
+var errUnauthorized = errors.New("Unauthorized")
+
+var ddbClient dynamodb.Client
+var tableName string
+var patLifetimeDaysMax int
+
+// PatItem is the part of a PAT's item that's needed to check it.
+type PatItem struct {
+	Pat        string   `dynamodbav:"Pat"`
+	Scopes     []string `dynamodbav:"Scopes"`
+	ExpiresAt  int64    `dynamodbav:"ExpiresAt"`
+	LastUsedAt int64    `dynamodbav:"LastUsedAt"`
+}
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ddbClient = *dynamodb.NewFromConfig(sdkConfig)
+
+	// Grab the DynamoDB table name from the environment variables.
+	// If the environment variable is not defined, fall back to a default.
+	tableName = os.Getenv(tableNameEnvVar)
+	if len(tableName) == 0 {
+		tableName = tableNameDefault
+	}
+
+	patLifetimeDaysMax = patLifetimeDaysMaxDefault
+	lifetimeStr := os.Getenv(patLifetimeDaysMaxEnvVar)
+	if len(lifetimeStr) > 0 {
+		patLifetimeDaysMax, err = strconv.Atoi(lifetimeStr)
+		if err != nil || patLifetimeDaysMax <= 0 {
+			log.Fatalf("Invalid %s '%s'", patLifetimeDaysMaxEnvVar, lifetimeStr)
+		}
+	}
+}
+
+// getPat looks up a PAT in the table maintained by the pats Lambda, returning
+// nil if it doesn't exist or has expired. PATs are stored as digests, but those
+// created before then may still be stored in plaintext, and are migrated the
+// first time they're checked.
+func getPat(ctx context.Context, pat string) (*PatItem, error) {
+	digest := pattoken.Hash(pat)
+	item, attributes, err := getPatItem(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		item, attributes, err = getPatItem(ctx, pat)
+		if err != nil || item == nil {
+			return nil, err
+		}
+		return migratePat(ctx, attributes, pat)
+	}
+	if item.ExpiresAt == 0 {
+		return assignExpiry(ctx, item)
+	}
+	return item, nil
+}
+
+// getPatItem gets the item stored under the given key, along with its raw
+// attributes, or nil if there isn't one or it has expired.
+func getPatItem(ctx context.Context, key string) (*PatItem, map[string]types.AttributeValue, error) {
+	tableKey, err := attributevalue.Marshal(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := ddbClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"Pat": tableKey,
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Item == nil {
+		return nil, nil, nil
+	}
+
+	// Expired PATs may not have been deleted by DynamoDB's TTL yet.
+	item := PatItem{}
+	err = attributevalue.UnmarshalMap(result.Item, &item)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pattoken.IsExpired(item.ExpiresAt, time.Now()) {
+		return nil, nil, nil
+	}
+	return &item, result.Item, nil
+}
+
+// legacyExpiresAt returns when a PAT created before PATs could expire should
+// expire, once it's migrated.
+func legacyExpiresAt() int64 {
+	return time.Now().AddDate(0, 0, patLifetimeDaysMax).Unix()
+}
+
+// migratePat moves a PAT that's stored in plaintext to its digest, keeping its
+// other attributes, and gives it an expiry if it doesn't have one. Both happen
+// in a single transaction, which only goes ahead if the plaintext item still
+// exists and nothing is stored under the digest, so a PAT that's deleted in
+// the meantime isn't recreated.
+func migratePat(ctx context.Context, attributes map[string]types.AttributeValue, pat string) (*PatItem, error) {
+	digest := pattoken.Hash(pat)
+	migrated := make(map[string]types.AttributeValue, len(attributes)+1)
+	for name, value := range attributes {
+		migrated[name] = value
+	}
+
+	var err error
+	migrated["Pat"], err = attributevalue.Marshal(digest)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := migrated[pattoken.ExpiresAtAttribute]; !ok {
+		migrated[pattoken.ExpiresAtAttribute], err = attributevalue.Marshal(legacyExpiresAt())
+		if err != nil {
+			return nil, err
+		}
+	}
+	plaintextKey, err := attributevalue.Marshal(pat)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = ddbClient.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:           aws.String(tableName),
+					Item:                migrated,
+					ConditionExpression: aws.String("attribute_not_exists(Pat)"),
+				},
+			},
+			{
+				Delete: &types.Delete{
+					TableName: aws.String(tableName),
+					Key: map[string]types.AttributeValue{
+						"Pat": plaintextKey,
+					},
+					ConditionExpression: aws.String("attribute_exists(Pat)"),
+				},
+			},
+		},
+	})
+
+	// The PAT was deleted, or migrated by another check, in the meantime, so
+	// whatever is now stored under its digest is what counts.
+	var transactionCanceled *types.TransactionCanceledException
+	if errors.As(err, &transactionCanceled) {
+		item, _, err := getPatItem(ctx, digest)
+		return item, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Migrated pat '%s' to its digest", pattoken.Redact(digest))
+	item := PatItem{}
+	err = attributevalue.UnmarshalMap(migrated, &item)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// assignExpiry gives a PAT that was created before PATs could expire an expiry.
+// As with recordLastUsed, the condition stops a deleted PAT from being
+// recreated.
+func assignExpiry(ctx context.Context, item *PatItem) (*PatItem, error) {
+	tableKey, err := attributevalue.Marshal(item.Pat)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := legacyExpiresAt()
+	value, err := attributevalue.Marshal(expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = ddbClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"Pat": tableKey,
+		},
+		UpdateExpression:    aws.String("SET #expiresAt = :expiresAt"),
+		ConditionExpression: aws.String("attribute_exists(Pat) AND attribute_not_exists(#expiresAt)"),
+		ExpressionAttributeNames: map[string]string{
+			"#expiresAt": pattoken.ExpiresAtAttribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":expiresAt": value,
+		},
+	})
+
+	// The PAT was deleted, or given an expiry by another check, in the
+	// meantime.
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		item, _, err := getPatItem(ctx, item.Pat)
+		return item, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item.ExpiresAt = expiresAt
+	return item, nil
+}
+
+// recordLastUsed records that a PAT has just been used, unless that was
+// recorded less than lastUsedInterval ago. The condition stops concurrent
+// checks of the same PAT from all writing, and stops a deleted PAT from being
+// recreated.
+func recordLastUsed(ctx context.Context, item *PatItem, now time.Time) error {
+	if now.Sub(time.Unix(item.LastUsedAt, 0)) < lastUsedInterval {
+		return nil
+	}
+
+	tableKey, err := attributevalue.Marshal(item.Pat)
+	if err != nil {
+		return err
+	}
+	values, err := attributevalue.MarshalMap(map[string]int64{
+		":now":       now.Unix(),
+		":threshold": now.Add(-lastUsedInterval).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = ddbClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"Pat": tableKey,
+		},
+		UpdateExpression:          aws.String("SET LastUsedAt = :now"),
+		ConditionExpression:       aws.String("attribute_exists(Pat) AND (attribute_not_exists(LastUsedAt) OR LastUsedAt < :threshold)"),
+		ExpressionAttributeValues: values,
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return nil
+	}
+	return err
+}
+
+// apiArn returns the ARN that covers every method of every route of the API
+// that a method ARN belongs to. API Gateway caches the policy for each PAT, and
+// reuses it on every route that the authorizer is attached to, so the policy
+// has to cover them all. Each Lambda checks the PAT's scopes itself.
+func apiArn(methodArn string) string {
+	// arn:aws:execute-api:<region>:<account>:<api>/<stage>/<method>/<path>
+	parts := strings.SplitN(methodArn, "/", 3)
+	if len(parts) < 2 {
+		return methodArn
+	}
+	return strings.Join([]string{parts[0], parts[1], "*", "*"}, "/")
+}
+
+func authorize(ctx context.Context, req events.APIGatewayCustomAuthorizerRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
+	// Malformed and mistyped PATs can be rejected without looking them up.
+	pat := pattoken.ParseBearerToken(req.AuthorizationToken)
+	if pattoken.Validate(pat) != nil {
+		return events.APIGatewayCustomAuthorizerResponse{}, errUnauthorized
+	}
+
+	item, err := getPat(ctx, pat)
+	if err != nil {
+		// Any other error is turned into a `500` by API Gateway.
+		log.Printf("Failed to get pat: %s", err)
+		return events.APIGatewayCustomAuthorizerResponse{}, err
+	}
+	if item == nil {
+		log.Printf("PAT '%s' is not valid", pattoken.Redact(pat))
+		return events.APIGatewayCustomAuthorizerResponse{}, errUnauthorized
+	}
+
+	// Failing to record when the PAT was used shouldn't stop it being used.
+	err = recordLastUsed(ctx, item, time.Now())
+	if err != nil {
+		log.Printf("Failed to record when pat '%s' was last used: %s", pattoken.Redact(pat), err)
+	}
+
+	scopes := item.Scopes
+	if scopes == nil {
+		scopes = pattoken.LegacyScopes
+	}
+	patId := pattoken.Hash(pat)
+	log.Printf("Authorised pat '%s' with scopes: %s", pattoken.Redact(patId), strings.Join(scopes, ","))
+
+	return events.APIGatewayCustomAuthorizerResponse{
+		PrincipalID: patId,
+		PolicyDocument: events.APIGatewayCustomAuthorizerPolicy{
+			Version: "2012-10-17",
+			Statement: []events.IAMPolicyStatement{
+				{
+					Action:   []string{"execute-api:Invoke"},
+					Effect:   "Allow",
+					Resource: []string{apiArn(req.MethodArn)},
+				},
+			},
+		},
+		Context: map[string]interface{}{
+			pattoken.ContextPatIdKey:  patId,
+			pattoken.ContextScopesKey: strings.Join(scopes, ","),
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(authorize)
+}
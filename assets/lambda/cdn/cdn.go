@@ -0,0 +1,95 @@
+// Package cdn looks up the domain name of the stack's CloudFront distribution,
+// so that the Lambdas can hand out links on it. The distribution sits in front
+// of the API, so it's created after the Lambdas, and its domain name can't be
+// passed to them as an environment variable. Instead, it's written to an SSM
+// parameter, whose name is passed to each Lambda in CDN_DOMAIN_PARAMETER.
+package cdn
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// The environment variable holding the name of the SSM parameter. It's only
+// set when the stack has a distribution.
+const ParameterEnvVar = string("CDN_DOMAIN_PARAMETER")
+
+// How long a domain name is used for before the parameter is read again, so
+// that a Lambda picks up a changed domain without a cold start.
+const RefreshInterval = 5 * time.Minute
+
+// ParameterGetter is the part of the SSM client used to read the parameter.
+type ParameterGetter interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// Domain reads the domain name from the parameter on first use, and caches it
+// for RefreshInterval. It's safe for concurrent use. A nil Domain has no
+// domain name.
+type Domain struct {
+	client    ParameterGetter
+	parameter string
+
+	mu        sync.Mutex
+	domain    string
+	fetchedAt time.Time
+}
+
+// New returns a Domain reading the parameter named by ParameterEnvVar, or nil
+// if it isn't set.
+func New(cfg aws.Config) *Domain {
+	parameter := os.Getenv(ParameterEnvVar)
+	if len(parameter) == 0 {
+		return nil
+	}
+	return NewWithClient(ssm.NewFromConfig(cfg), parameter)
+}
+
+// NewWithClient returns a Domain reading the given parameter with client.
+func NewWithClient(client ParameterGetter, parameter string) *Domain {
+	return &Domain{
+		client:    client,
+		parameter: parameter,
+	}
+}
+
+// Get returns the domain name, or an empty string if the stack has no
+// distribution yet. If the parameter can't be read, the last domain name read
+// is returned along with the error, and the parameter is read again on the
+// next call.
+func (d *Domain) Get(ctx context.Context) (string, error) {
+	if d == nil {
+		return "", nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.fetchedAt.IsZero() && time.Since(d.fetchedAt) < RefreshInterval {
+		return d.domain, nil
+	}
+
+	result, err := d.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name: aws.String(d.parameter),
+	})
+	var notFound *types.ParameterNotFound
+	if errors.As(err, &notFound) {
+		d.domain = ""
+		d.fetchedAt = time.Now()
+		return d.domain, nil
+	}
+	if err != nil {
+		return d.domain, err
+	}
+
+	d.domain = aws.ToString(result.Parameter.Value)
+	d.fetchedAt = time.Now()
+	return d.domain, nil
+}
@@ -0,0 +1,90 @@
+package cdn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+type fakeClient struct {
+	value string
+	err   error
+	calls int
+}
+
+func (client *fakeClient) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	client.calls++
+	if client.err != nil {
+		return nil, client.err
+	}
+	return &ssm.GetParameterOutput{
+		Parameter: &types.Parameter{Value: aws.String(client.value)},
+	}, nil
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"found", nil, "d111111abcdef8.cloudfront.net"},
+		{"not found", &types.ParameterNotFound{}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &fakeClient{value: test.want, err: test.err}
+			domain := NewWithClient(client, "/xaas/cdn-domain")
+			for i := 0; i < 2; i++ {
+				got, err := domain.Get(context.Background())
+				if err != nil {
+					t.Fatalf("Get() returned an error: %s", err)
+				}
+				if got != test.want {
+					t.Errorf("Get() = %q, want %q", got, test.want)
+				}
+			}
+			if client.calls != 1 {
+				t.Errorf("GetParameter() was called %d times, want 1", client.calls)
+			}
+		})
+	}
+}
+
+func TestGetError(t *testing.T) {
+	client := &fakeClient{value: "d111111abcdef8.cloudfront.net"}
+	domain := NewWithClient(client, "/xaas/cdn-domain")
+	_, err := domain.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() returned an error: %s", err)
+	}
+
+	// Once the cached domain is stale, a failed read keeps returning it.
+	domain.fetchedAt = time.Now().Add(-RefreshInterval)
+	client.err = errors.New("throttled")
+	for i := 0; i < 2; i++ {
+		got, err := domain.Get(context.Background())
+		if err == nil {
+			t.Errorf("Get() returned no error")
+		}
+		if got != client.value {
+			t.Errorf("Get() = %q, want %q", got, client.value)
+		}
+	}
+	if client.calls != 3 {
+		t.Errorf("GetParameter() was called %d times, want 3", client.calls)
+	}
+}
+
+func TestGetNil(t *testing.T) {
+	var domain *Domain
+	got, err := domain.Get(context.Background())
+	if got != "" || err != nil {
+		t.Errorf("Get() = %q, %v, want an empty domain", got, err)
+	}
+}
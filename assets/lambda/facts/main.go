@@ -2,13 +2,21 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -18,16 +26,123 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"cdn"
+	"pattoken"
 )
 
 const tableNameEnvVar = string("FACTS_TABLE_NAME")
 const tableNameDefault = string("xaas-api-facts")
+const listLimitDefault = int(25)
+const listLimitMax = int(100)
+
+// BatchGetItem accepts at most 100 keys per request, so that is also the most
+// facts that can be asked for at once through `?ids=`. Any keys DynamoDB
+// leaves unprocessed are retried, backing off exponentially.
+const batchGetLimitMax = int(100)
+const batchGetMaxAttempts = int(5)
+const batchGetBackoffBase = 50 * time.Millisecond
+
+// The number of facts tried when picking one at random, or as the fact of the
+// day, in case some of those listed in the seed index have since been deleted.
+const factPickMaxAttempts = int(5)
+const animalsEnvVar = string("ANIMALS")
+const animalsDefault = string("animal")
+
+// The layout of the `date` query parameter accepted by the fact of the day.
+const factOfTheDayDateLayout = string("2006-01-02")
+
+// Facts are written in English unless stated otherwise, and English is served
+// when none of the languages a client asks for are available.
+const defaultLanguage = string("en")
+
+// Facts with a negative FactId are reserved for internal bookkeeping, and are
+// never returned by the API. Each animal's counter item holds the last FactId
+// handed out to a fact created through the API, along with the FactIds of the
+// facts that have been created at runtime. Each animal's seed index item is
+// written at deploy time, and holds the FactIds of the facts seeded from its
+// `facts.txt`, along with those of any seeded facts since deleted at runtime.
+// Every deploy that changes the seeded facts rewrites the seed index, which
+// empties the deleted set, so that a deleted fact seeded again is listed.
+const counterFactId = int(-1)
+const seedIndexFactId = int(-2)
+
+// Facts created through the API are allocated FactIds starting from
+// runtimeFactIdStart. The facts seeded from `facts.txt` at deploy time are
+// numbered from 0, so keeping the two ranges apart means that a later deploy
+// can never overwrite a fact that was added at runtime.
+const runtimeFactIdStart = int(1000000)
 
 var ddbClient dynamodb.Client
+var tableName string
+var animals []string
+
+// Behind the stack's CloudFront distribution, links to further pages are on
+// the distribution. Its domain name is read from the stack's parameter.
+var cdnDomain *cdn.Domain
 
+// Fact is a single fact about an animal. Text is written in the language given
+// by Lang, and Translations holds the same fact in other languages, keyed by
+// language code. Facts are localised before they are returned, so only the
+// best-matching language is ever sent to a client. Source is a URL citing
+// where the fact came from.
 type Fact struct {
+	Animal       string            `dynamodbav:"Animal" json:"animal"`
+	FactId       int               `dynamodbav:"FactId" json:"id"`
+	Text         string            `dynamodbav:"Text" json:"text"`
+	Lang         string            `dynamodbav:"Lang" json:"lang"`
+	Source       string            `dynamodbav:"Source,omitempty" json:"source,omitempty"`
+	Tags         []string          `dynamodbav:"Tags,omitempty" json:"tags,omitempty"`
+	Translations map[string]string `dynamodbav:"Translations,omitempty" json:"-"`
+}
+
+// factIndex holds the bookkeeping attributes of the counter and seed index
+// items. Together, they describe every fact in the table, without having to
+// Scan it. Seed index items written before facts could have their own IDs
+// only hold a FactCount, as the seeded FactIds were always 0 to FactCount-1.
+type factIndex struct {
+	FactCount      int   `dynamodbav:"FactCount"`
+	FactIds        []int `dynamodbav:"FactIds,numberset"`
+	DeletedFactIds []int `dynamodbav:"DeletedFactIds,numberset"`
+}
+
+// FactOfTheDay is the fact chosen for a calendar date.
+type FactOfTheDay struct {
+	Date string `json:"date"`
+	Fact
+}
+
+// FactRequest is the body accepted when creating or updating a fact. Lang
+// defaults to English.
+type FactRequest struct {
+	Text         string            `json:"text"`
+	Lang         string            `json:"lang"`
+	Source       string            `json:"source"`
+	Tags         []string          `json:"tags"`
+	Translations map[string]string `json:"translations"`
+}
+
+// FactPage is a single page of facts returned by the listing endpoint. Cursor
+// is an opaque token that can be passed back to fetch the following page, and
+// Next is a ready-made link to that page. Both are omitted on the last page.
+type FactPage struct {
+	Facts  []Fact `json:"facts"`
+	Cursor string `json:"cursor,omitempty"`
+	Next   string `json:"next,omitempty"`
+}
+
+// FactBatch is the response of the batch endpoint. Facts are returned in the
+// order they were requested, with a null in place of each fact that doesn't
+// exist.
+type FactBatch struct {
+	Facts []*Fact `json:"facts"`
+}
+
+// factKey mirrors the composite key of the facts table. It is also what gets
+// encoded into (and decoded from) the opaque cursor token.
+type factKey struct {
+	Animal string `dynamodbav:"Animal" json:"animal"`
 	FactId int    `dynamodbav:"FactId" json:"id"`
-	Text   string `dynamodbav:"Text" json:"text"`
 }
 
 func init() {
@@ -37,6 +152,23 @@ func init() {
 	}
 
 	ddbClient = *dynamodb.NewFromConfig(sdkConfig)
+	cdnDomain = cdn.New(sdkConfig)
+
+	// Grab the name of the fact table from the environment variables.
+	// If the environment variable is not defined, fall back to a default.
+	tableName = os.Getenv(tableNameEnvVar)
+	if len(tableName) == 0 {
+		tableName = tableNameDefault
+	}
+
+	// Grab the comma-separated list of animals served by this stack from the
+	// environment variables. If the environment variable is not defined, fall
+	// back to a default.
+	animalList := os.Getenv(animalsEnvVar)
+	if len(animalList) == 0 {
+		animalList = animalsDefault
+	}
+	animals = strings.Split(animalList, ",")
 }
 
 func clientError(status int) (events.APIGatewayProxyResponse, error) {
@@ -46,6 +178,15 @@ func clientError(status int) (events.APIGatewayProxyResponse, error) {
 	}, nil
 }
 
+// missingScope is returned when a valid PAT hasn't been granted the scope that
+// the route needs.
+func missingScope(scope string) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{
+		Body:       fmt.Sprintf("%s: the PAT doesn't have the '%s' scope", http.StatusText(http.StatusForbidden), scope),
+		StatusCode: http.StatusForbidden,
+	}, nil
+}
+
 func serverError(err error) (events.APIGatewayProxyResponse, error) {
 	return events.APIGatewayProxyResponse{
 		Body:       http.StatusText(http.StatusInternalServerError),
@@ -53,44 +194,244 @@ func serverError(err error) (events.APIGatewayProxyResponse, error) {
 	}, nil
 }
 
-func getFact(ctx context.Context, factId int) (*Fact, error) {
-	// Grab the name of the fact table from the environment variables.
-	// If the environment variable is not defined, fall back to a default.
-	tableName := os.Getenv(tableNameEnvVar)
-	if len(tableName) == 0 {
-		tableName = tableNameDefault
+// isKnownAnimal reports whether the stack serves facts about an animal.
+func isKnownAnimal(animal string) bool {
+	for _, knownAnimal := range animals {
+		if knownAnimal == animal {
+			return true
+		}
 	}
+	return false
+}
 
-	// Get a random fact
-	if factId == -1 {
-		// Count the number of facts.
-		scanResults, err := ddbClient.Scan(context.TODO(), &dynamodb.ScanInput{
-			TableName: aws.String(tableName),
-			Select:    types.SelectCount,
-		})
-		if err != nil {
-			return nil, err
+// marshalFactKey builds the composite key of a fact.
+func marshalFactKey(animal string, factId int) (map[string]types.AttributeValue, error) {
+	return attributevalue.MarshalMap(factKey{
+		Animal: animal,
+		FactId: factId,
+	})
+}
+
+// getFactIndex reads one of an animal's bookkeeping items. A missing item is
+// treated as an empty index.
+func getFactIndex(ctx context.Context, animal string, indexFactId int) (*factIndex, error) {
+	tableKey, err := marshalFactKey(animal, indexFactId)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := ddbClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key:       tableKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	index := new(factIndex)
+	err = attributevalue.UnmarshalMap(result.Item, index)
+	if err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// seededFactIds lists the FactIds of the facts in a seed index, including any
+// that have since been deleted.
+func (index *factIndex) seededFactIds() []int {
+	if len(index.FactIds) > 0 {
+		return index.FactIds
+	}
+	factIds := make([]int, 0, index.FactCount)
+	for factId := 0; factId < index.FactCount; factId++ {
+		factIds = append(factIds, factId)
+	}
+	return factIds
+}
+
+// deletedFactIds returns the set of seeded facts deleted since the seed index
+// was written.
+func (index *factIndex) deletedFactIds() map[int]bool {
+	deletedFactIds := make(map[int]bool, len(index.DeletedFactIds))
+	for _, factId := range index.DeletedFactIds {
+		deletedFactIds[factId] = true
+	}
+	return deletedFactIds
+}
+
+// getFactIds lists the FactIds of every fact about an animal, in ascending
+// order, using only the two bookkeeping items rather than a Scan. The seeded
+// facts are listed in the seed index, minus any that have since been deleted,
+// and the facts created at runtime are listed in the counter item.
+func getFactIds(ctx context.Context, animal string) ([]int, error) {
+	seedIndex, err := getFactIndex(ctx, animal, seedIndexFactId)
+	if err != nil {
+		return nil, err
+	}
+
+	runtimeIndex, err := getFactIndex(ctx, animal, counterFactId)
+	if err != nil {
+		return nil, err
+	}
+
+	seededFactIds := seedIndex.seededFactIds()
+	deletedFactIds := seedIndex.deletedFactIds()
+
+	factIds := make([]int, 0, len(seededFactIds)+len(runtimeIndex.FactIds))
+	for _, factId := range seededFactIds {
+		if !deletedFactIds[factId] {
+			factIds = append(factIds, factId)
 		}
-		factCount := int(scanResults.Count)
-		if factCount <= 0 {
-			return nil, fmt.Errorf("no facts found in dynamodb table %s", tableName)
-		} else if factCount == 1 {
-			factId = 0
-		} else {
-			factId = rand.Intn(factCount - 1)
+	}
+	factIds = append(factIds, runtimeIndex.FactIds...)
+	sort.Ints(factIds)
+
+	if len(factIds) == 0 {
+		return nil, fmt.Errorf(
+			"no '%s' facts found in dynamodb table %s",
+			animal,
+			tableName,
+		)
+	}
+
+	return factIds, nil
+}
+
+// getRandomFact picks a fact uniformly from every fact about an animal. A
+// deploy that rewrites the seed index clears its deleted set, so a seeded fact
+// that was deleted at runtime, and not seeded again, may be listed even though
+// it no longer exists. Any such fact is recorded as deleted once more, and
+// another is picked in its place.
+func getRandomFact(ctx context.Context, animal string) (*Fact, error) {
+	factIds, err := getFactIds(ctx, animal)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < factPickMaxAttempts && len(factIds) > 0; attempt++ {
+		i := rand.Intn(len(factIds))
+		factId := factIds[i]
+
+		fact, err := getFact(ctx, animal, factId)
+		if err != nil || fact != nil {
+			return fact, err
 		}
+
+		if factId < runtimeFactIdStart {
+			err = forgetSeededFact(ctx, animal, factId)
+			if err != nil {
+				log.Printf("Failed to record that '%s' fact %d was deleted: %s", animal, factId, err)
+			}
+		}
+		factIds = append(factIds[:i], factIds[i+1:]...)
 	}
 
-	tableKey, err := attributevalue.Marshal(factId)
+	return nil, fmt.Errorf("no '%s' facts found in dynamodb table %s", animal, tableName)
+}
+
+// forgetSeededFact records that a seeded fact no longer exists in the seed
+// index, so that it isn't picked again until the next deploy seeds it.
+func forgetSeededFact(ctx context.Context, animal string, factId int) error {
+	indexUpdate, err := updateFactIndex(animal, factId, false)
+	if err != nil {
+		return err
+	}
+
+	_, err = ddbClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 indexUpdate.Update.TableName,
+		Key:                       indexUpdate.Update.Key,
+		UpdateExpression:          indexUpdate.Update.UpdateExpression,
+		ExpressionAttributeValues: indexUpdate.Update.ExpressionAttributeValues,
+	})
+	return err
+}
+
+// getFactOfTheDay deterministically maps a calendar date to a fact, so that
+// every caller sees the same fact all day. Only the seeded facts take part, so
+// the rotation is only reshuffled by a deploy that changes them, and never by
+// facts being created or deleted through the API. If the day's fact has been
+// deleted since it was seeded, the next fact in the rotation is used instead.
+func getFactOfTheDay(ctx context.Context, animal string, date time.Time) (*Fact, error) {
+	seedIndex, err := getFactIndex(ctx, animal, seedIndexFactId)
+	if err != nil {
+		return nil, err
+	}
+
+	deletedFactIds := seedIndex.deletedFactIds()
+	attempts := 0
+	for _, factId := range factOfTheDayOrder(animal, seedIndex.seededFactIds(), date) {
+		if deletedFactIds[factId] {
+			continue
+		}
+		if attempts == factPickMaxAttempts {
+			break
+		}
+		attempts++
+
+		fact, err := getFact(ctx, animal, factId)
+		if err != nil || fact != nil {
+			return fact, err
+		}
+
+		err = forgetSeededFact(ctx, animal, factId)
+		if err != nil {
+			log.Printf("Failed to record that '%s' fact %d was deleted: %s", animal, factId, err)
+		}
+	}
+
+	return nil, fmt.Errorf("no seeded '%s' facts found in dynamodb table %s", animal, tableName)
+}
+
+// factOfTheDayOrder lists the FactIds in the order they're tried as the fact
+// of the day for a calendar date, starting with the fact of the day itself,
+// followed by those of the days after it. The days are split into cycles as
+// long as the number of facts, and each cycle works through its own shuffle of
+// the facts, so no fact is repeated until every fact has been shown.
+func factOfTheDayOrder(animal string, factIds []int, date time.Time) []int {
+	if len(factIds) == 0 {
+		return nil
+	}
+
+	// Count the days since the Unix epoch, using the date's own calendar day
+	// rather than an instant in time.
+	year, month, day := date.Date()
+	dayNumber := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400
+
+	factCount := int64(len(factIds))
+	cycle := dayNumber / factCount
+	position := dayNumber % factCount
+	if position < 0 {
+		cycle--
+		position += factCount
+	}
+
+	// Seed the shuffle with the animal and the cycle, so each animal has its
+	// own rotation. The FactIds are sorted first, so the rotation doesn't
+	// depend on the order they were listed in.
+	shuffled := append([]int{}, factIds...)
+	sort.Ints(shuffled)
+	seed := fnv.New64a()
+	fmt.Fprintf(seed, "%s/%d", animal, cycle)
+	shuffle := rand.New(rand.NewSource(int64(seed.Sum64())))
+	shuffle.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	order := append([]int{}, shuffled[position:]...)
+	return append(order, shuffled[:position]...)
+}
+
+// getFact fetches a single fact, returning nil if it doesn't exist.
+func getFact(ctx context.Context, animal string, factId int) (*Fact, error) {
+	tableKey, err := marshalFactKey(animal, factId)
 	if err != nil {
 		return nil, err
 	}
 
 	input := &dynamodb.GetItemInput{
 		TableName: aws.String(tableName),
-		Key: map[string]types.AttributeValue{
-			"FactId": tableKey,
-		},
+		Key:       tableKey,
 	}
 
 	result, err := ddbClient.GetItem(ctx, input)
@@ -111,8 +452,111 @@ func getFact(ctx context.Context, factId int) (*Fact, error) {
 	return fact, nil
 }
 
-func processGet(ctx context.Context, factId int) (events.APIGatewayProxyResponse, error) {
-	fact, err := getFact(ctx, factId)
+// getPreferredLanguages returns the languages the client would like facts in,
+// most preferred first. The `lang` query parameter takes precedence over the
+// Accept-Language header.
+func getPreferredLanguages(req events.APIGatewayProxyRequest) []string {
+	languages := make([]string, 0)
+	lang, ok := req.QueryStringParameters["lang"]
+	if ok && len(lang) > 0 {
+		languages = append(languages, strings.ToLower(strings.TrimSpace(lang)))
+	}
+
+	header, ok := getHeader(req.Headers, "Accept-Language")
+	if ok {
+		languages = append(languages, parseAcceptLanguage(header)...)
+	}
+
+	return languages
+}
+
+// parseAcceptLanguage parses an Accept-Language header value, such as
+// `fr-CA,fr;q=0.9,en;q=0.8`, into a list of lower-case language tags ordered
+// by their quality values. Wildcards, and tags with a quality of 0, are
+// dropped.
+func parseAcceptLanguage(header string) []string {
+	type weightedLanguage struct {
+		tag     string
+		quality float64
+	}
+
+	weightedLanguages := make([]weightedLanguage, 0)
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if len(tag) == 0 || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.TrimSpace(key) == "q" {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err == nil {
+					quality = parsed
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		weightedLanguages = append(weightedLanguages, weightedLanguage{
+			tag:     tag,
+			quality: quality,
+		})
+	}
+
+	sort.SliceStable(weightedLanguages, func(i, j int) bool {
+		return weightedLanguages[i].quality > weightedLanguages[j].quality
+	})
+
+	languages := make([]string, 0, len(weightedLanguages))
+	for _, weighted := range weightedLanguages {
+		languages = append(languages, weighted.tag)
+	}
+	return languages
+}
+
+// localiseFact replaces the text of a fact with the best match for the
+// preferred languages. An exact match is preferred, then a match on the
+// primary language (so `fr-ca` will match `fr`), and if nothing matches the
+// fact is served in English, or failing that, as written.
+func localiseFact(fact *Fact, languages []string) {
+	if len(fact.Lang) == 0 {
+		fact.Lang = defaultLanguage
+	}
+
+	available := map[string]string{
+		fact.Lang: fact.Text,
+	}
+	for lang, text := range fact.Translations {
+		available[strings.ToLower(lang)] = text
+	}
+	fact.Translations = nil
+
+	candidates := append([]string{}, languages...)
+	for _, lang := range languages {
+		primary, _, found := strings.Cut(lang, "-")
+		if found {
+			candidates = append(candidates, primary)
+		}
+	}
+	candidates = append(candidates, defaultLanguage)
+
+	for _, lang := range candidates {
+		text, ok := available[lang]
+		if ok {
+			fact.Lang = lang
+			fact.Text = text
+			return
+		}
+	}
+}
+
+func processGet(ctx context.Context, animal string, factId int, languages []string) (events.APIGatewayProxyResponse, error) {
+	fact, err := getFact(ctx, animal, factId)
 	if err != nil {
 		log.Printf("Failed to get fact: %s", err)
 		return serverError(err)
@@ -123,6 +567,29 @@ func processGet(ctx context.Context, factId int) (events.APIGatewayProxyResponse
 		return clientError(http.StatusNotFound)
 	}
 
+	return localisedFactResponse(fact, languages)
+}
+
+func processGetRandom(ctx context.Context, animal string, languages []string) (events.APIGatewayProxyResponse, error) {
+	fact, err := getRandomFact(ctx, animal)
+	if err != nil {
+		log.Printf("Failed to get random fact: %s", err)
+		return serverError(err)
+	}
+
+	// Every request should get a fresh pick, so the distribution mustn't
+	// cache it.
+	res, err := localisedFactResponse(fact, languages)
+	if res.Headers != nil {
+		res.Headers["Cache-Control"] = "no-store"
+	}
+	return res, err
+}
+
+// localisedFactResponse localises a single fact and returns it to the client.
+func localisedFactResponse(fact *Fact, languages []string) (events.APIGatewayProxyResponse, error) {
+	localiseFact(fact, languages)
+
 	json, err := json.Marshal(fact)
 	if err != nil {
 		log.Printf("Failed to json.Marshal(fact): %s", err)
@@ -132,19 +599,803 @@ func processGet(ctx context.Context, factId int) (events.APIGatewayProxyResponse
 
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusOK,
+		Headers:    languageHeaders(fact.Lang),
+		Body:       string(json),
+	}, nil
+}
+
+// languageHeaders returns the headers describing the language of a response.
+// The response varies with Accept-Language, so caches must key on it too.
+func languageHeaders(lang string) map[string]string {
+	headers := map[string]string{
+		"Vary": "Accept-Language",
+	}
+	if len(lang) > 0 {
+		headers["Content-Language"] = lang
+	}
+	return headers
+}
+
+// encodeCursor turns the LastEvaluatedKey of a Query into an opaque,
+// URL-safe token.
+func encodeCursor(lastEvaluatedKey map[string]types.AttributeValue) (string, error) {
+	var cursor factKey
+	err := attributevalue.UnmarshalMap(lastEvaluatedKey, &cursor)
+	if err != nil {
+		return "", err
+	}
+
+	cursorJson, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(cursorJson), nil
+}
+
+// decodeCursor reverses encodeCursor, returning a key that can be used as the
+// ExclusiveStartKey of a Query. Cursors issued for a different animal are
+// rejected.
+func decodeCursor(animal string, token string) (map[string]types.AttributeValue, error) {
+	cursorJson, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor factKey
+	err = json.Unmarshal(cursorJson, &cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if cursor.Animal != animal {
+		return nil, fmt.Errorf("cursor is for '%s', not '%s'", cursor.Animal, animal)
+	}
+
+	return attributevalue.MarshalMap(cursor)
+}
+
+// listFacts queries a page of an animal's facts. The reserved (negative)
+// FactIds are excluded by the key condition.
+func listFacts(ctx context.Context, animal string, limit int, startKey map[string]types.AttributeValue) ([]Fact, map[string]types.AttributeValue, error) {
+	queryResults, err := ddbClient.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		Limit:                  aws.Int32(int32(limit)),
+		ExclusiveStartKey:      startKey,
+		KeyConditionExpression: aws.String("Animal = :animal AND FactId >= :zero"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":animal": &types.AttributeValueMemberS{Value: animal},
+			":zero":   &types.AttributeValueMemberN{Value: "0"},
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	facts := make([]Fact, 0, len(queryResults.Items))
+	err = attributevalue.UnmarshalListOfMaps(queryResults.Items, &facts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return facts, queryResults.LastEvaluatedKey, nil
+}
+
+// getCdnDomain returns the domain name of the stack's distribution, or an
+// empty string if it has none.
+func getCdnDomain(ctx context.Context) string {
+	domain, err := cdnDomain.Get(ctx)
+	if err != nil {
+		log.Printf("Failed to get the CDN domain: %s", err)
+	}
+	return domain
+}
+
+// nextLink builds the link to the page following the current one. The stage
+// name is included, as API Gateway strips it from the request path.
+func nextLink(ctx context.Context, req events.APIGatewayProxyRequest, limit int, cursor string) string {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("cursor", cursor)
+	lang, ok := req.QueryStringParameters["lang"]
+	if ok {
+		query.Set("lang", lang)
+	}
+
+	// The distribution adds the stage to the path itself.
+	domain := getCdnDomain(ctx)
+	if len(domain) > 0 {
+		return fmt.Sprintf("https://%s%s?%s", domain, req.Path, query.Encode())
+	}
+
+	path := req.Path
+	if len(req.RequestContext.Stage) > 0 {
+		path = fmt.Sprintf("/%s%s", req.RequestContext.Stage, path)
+	}
+
+	host, ok := getHeader(req.Headers, "Host")
+	if !ok {
+		return fmt.Sprintf("%s?%s", path, query.Encode())
+	}
+	return fmt.Sprintf("https://%s%s?%s", host, path, query.Encode())
+}
+
+func processList(ctx context.Context, animal string, req events.APIGatewayProxyRequest, languages []string) (events.APIGatewayProxyResponse, error) {
+	limit := listLimitDefault
+	limitStr, ok := req.QueryStringParameters["limit"]
+	if ok {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return clientError(http.StatusBadRequest)
+		}
+		if limit > listLimitMax {
+			limit = listLimitMax
+		}
+	}
+
+	var startKey map[string]types.AttributeValue
+	cursorStr, ok := req.QueryStringParameters["cursor"]
+	if ok && len(cursorStr) > 0 {
+		var err error
+		startKey, err = decodeCursor(animal, cursorStr)
+		if err != nil {
+			log.Printf("Failed to decode cursor '%s': %s", cursorStr, err)
+			return clientError(http.StatusBadRequest)
+		}
+	}
+
+	facts, lastEvaluatedKey, err := listFacts(ctx, animal, limit, startKey)
+	if err != nil {
+		log.Printf("Failed to list facts: %s", err)
+		return serverError(err)
+	}
+
+	// The Content-Language is only sent if every fact on the page could be
+	// served in the same language.
+	pageLang := ""
+	for i := range facts {
+		localiseFact(&facts[i], languages)
+		if i == 0 {
+			pageLang = facts[i].Lang
+		} else if facts[i].Lang != pageLang {
+			pageLang = ""
+		}
+	}
+
+	page := FactPage{
+		Facts: facts,
+	}
+
+	if len(lastEvaluatedKey) > 0 {
+		page.Cursor, err = encodeCursor(lastEvaluatedKey)
+		if err != nil {
+			log.Printf("Failed to encode cursor: %s", err)
+			return serverError(err)
+		}
+		page.Next = nextLink(ctx, req, limit, page.Cursor)
+	}
+
+	json, err := json.Marshal(page)
+	if err != nil {
+		log.Printf("Failed to json.Marshal(page): %s", err)
+		return serverError(err)
+	}
+	log.Printf("Successfully listed %d '%s' facts", len(page.Facts), animal)
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    languageHeaders(pageLang),
+		Body:       string(json),
+	}, nil
+}
+
+// parseFactIds parses the comma-separated `ids` query parameter, preserving the
+// order (and any repeats) of the requested FactIds.
+func parseFactIds(idsStr string) ([]int, error) {
+	idStrs := strings.Split(idsStr, ",")
+	if len(idStrs) > batchGetLimitMax {
+		return nil, fmt.Errorf("at most %d ids may be requested, got %d", batchGetLimitMax, len(idStrs))
+	}
+
+	factIds := make([]int, 0, len(idStrs))
+	for _, idStr := range idStrs {
+		factId, err := strconv.Atoi(strings.TrimSpace(idStr))
+		if err != nil {
+			return nil, err
+		}
+		factIds = append(factIds, factId)
+	}
+
+	return factIds, nil
+}
+
+// batchGetFacts fetches the given facts with BatchGetItem, retrying any keys
+// left unprocessed. The facts are returned keyed by FactId, and facts that
+// don't exist are simply absent. The reserved (negative) FactIds are never
+// fetched.
+func batchGetFacts(ctx context.Context, animal string, factIds []int) (map[int]*Fact, error) {
+	// BatchGetItem rejects requests containing the same key twice.
+	keys := make([]map[string]types.AttributeValue, 0, len(factIds))
+	seen := make(map[int]bool, len(factIds))
+	for _, factId := range factIds {
+		if factId < 0 || seen[factId] {
+			continue
+		}
+		seen[factId] = true
+
+		tableKey, err := marshalFactKey(animal, factId)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, tableKey)
+	}
+
+	facts := make(map[int]*Fact, len(keys))
+	if len(keys) == 0 {
+		return facts, nil
+	}
+
+	requestItems := map[string]types.KeysAndAttributes{
+		tableName: {Keys: keys},
+	}
+	for attempt := 0; len(requestItems) > 0; attempt++ {
+		if attempt >= batchGetMaxAttempts {
+			return nil, fmt.Errorf("%d keys still unprocessed after %d attempts", len(requestItems[tableName].Keys), attempt)
+		}
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(batchGetBackoffBase << (attempt - 1)):
+			}
+		}
+
+		result, err := ddbClient.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: requestItems,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range result.Responses[tableName] {
+			fact := new(Fact)
+			err = attributevalue.UnmarshalMap(item, fact)
+			if err != nil {
+				return nil, err
+			}
+			facts[fact.FactId] = fact
+		}
+
+		requestItems = result.UnprocessedKeys
+	}
+
+	return facts, nil
+}
+
+func processBatchGet(ctx context.Context, animal string, idsStr string, languages []string) (events.APIGatewayProxyResponse, error) {
+	factIds, err := parseFactIds(idsStr)
+	if err != nil {
+		log.Printf("Invalid ids '%s': %s", idsStr, err)
+		return clientError(http.StatusBadRequest)
+	}
+
+	found, err := batchGetFacts(ctx, animal, factIds)
+	if err != nil {
+		log.Printf("Failed to batch get facts: %s", err)
+		return serverError(err)
+	}
+
+	// As with listing, the Content-Language is only sent if every fact that
+	// was found could be served in the same language. Repeated FactIds share
+	// the same fact, so each is only localised once.
+	batch := FactBatch{
+		Facts: make([]*Fact, len(factIds)),
+	}
+	localised := make(map[int]bool, len(found))
+	batchLang := ""
+	first := true
+	for i, factId := range factIds {
+		fact, ok := found[factId]
+		if !ok {
+			continue
+		}
+		if !localised[factId] {
+			localiseFact(fact, languages)
+			localised[factId] = true
+		}
+		if first {
+			batchLang = fact.Lang
+			first = false
+		} else if fact.Lang != batchLang {
+			batchLang = ""
+		}
+		batch.Facts[i] = fact
+	}
+
+	json, err := json.Marshal(batch)
+	if err != nil {
+		log.Printf("Failed to json.Marshal(batch): %s", err)
+		return serverError(err)
+	}
+	log.Printf("Successfully fetched %d of %d '%s' facts", len(found), len(factIds), animal)
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    languageHeaders(batchLang),
+		Body:       string(json),
+	}, nil
+}
+
+// processGetToday returns the fact of the day. The date defaults to today in
+// the requested timezone (UTC by default), and the response can be cached
+// until the end of the current day in that timezone.
+func processGetToday(ctx context.Context, animal string, req events.APIGatewayProxyRequest, languages []string) (events.APIGatewayProxyResponse, error) {
+	location := time.UTC
+	timezone, ok := req.QueryStringParameters["tz"]
+	if ok && len(timezone) > 0 {
+		var err error
+		location, err = time.LoadLocation(timezone)
+		if err != nil {
+			log.Printf("Invalid timezone '%s': %s", timezone, err)
+			return clientError(http.StatusBadRequest)
+		}
+	}
+
+	now := time.Now().In(location)
+	date := now
+	dateStr, ok := req.QueryStringParameters["date"]
+	if ok && len(dateStr) > 0 {
+		var err error
+		date, err = time.ParseInLocation(factOfTheDayDateLayout, dateStr, location)
+		if err != nil {
+			log.Printf("Invalid date '%s': %s", dateStr, err)
+			return clientError(http.StatusBadRequest)
+		}
+	}
+
+	fact, err := getFactOfTheDay(ctx, animal, date)
+	if err != nil {
+		log.Printf("Failed to get the fact of the day: %s", err)
+		return serverError(err)
+	}
+
+	localiseFact(fact, languages)
+
+	json, err := json.Marshal(FactOfTheDay{
+		Date: date.Format(factOfTheDayDateLayout),
+		Fact: *fact,
+	})
+	if err != nil {
+		log.Printf("Failed to json.Marshal(fact): %s", err)
+		return serverError(err)
+	}
+	log.Printf("Successfully fetched fact of the day: %s", json)
+
+	// Let clients and caches hold on to the response until midnight.
+	year, month, day := now.Date()
+	nextDay := time.Date(year, month, day+1, 0, 0, 0, 0, location)
+	maxAge := int(nextDay.Sub(now).Seconds())
+
+	headers := languageHeaders(fact.Lang)
+	headers["Cache-Control"] = fmt.Sprintf("public, max-age=%d", maxAge)
+	headers["Expires"] = nextDay.UTC().Format(http.TimeFormat)
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
 		Body:       string(json),
 	}, nil
 }
 
+// getHeader looks up a request header, ignoring the case of its name.
+func getHeader(headers map[string]string, name string) (string, bool) {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// parseFactRequest decodes and validates the body of a create/update request.
+func parseFactRequest(req events.APIGatewayProxyRequest) (*FactRequest, error) {
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		var err error
+		body, err = base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	factRequest := new(FactRequest)
+	err := json.Unmarshal(body, factRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	factRequest.Text = strings.TrimSpace(factRequest.Text)
+	if len(factRequest.Text) == 0 {
+		return nil, fmt.Errorf("fact text must not be empty")
+	}
+
+	factRequest.Source = strings.TrimSpace(factRequest.Source)
+	if len(factRequest.Source) > 0 {
+		sourceUrl, err := url.Parse(factRequest.Source)
+		if err != nil || (sourceUrl.Scheme != "http" && sourceUrl.Scheme != "https") || len(sourceUrl.Host) == 0 {
+			return nil, fmt.Errorf("fact source must be a http(s) URL")
+		}
+	}
+
+	for _, tag := range factRequest.Tags {
+		if len(strings.TrimSpace(tag)) == 0 {
+			return nil, fmt.Errorf("fact tags must not be empty")
+		}
+	}
+
+	factRequest.Lang = strings.ToLower(strings.TrimSpace(factRequest.Lang))
+	if len(factRequest.Lang) == 0 {
+		factRequest.Lang = defaultLanguage
+	}
+
+	translations := make(map[string]string, len(factRequest.Translations))
+	for lang, text := range factRequest.Translations {
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		text = strings.TrimSpace(text)
+		if len(lang) == 0 || len(text) == 0 {
+			return nil, fmt.Errorf("translations must have a language and text")
+		}
+		translations[lang] = text
+	}
+	factRequest.Translations = translations
+
+	return factRequest, nil
+}
+
+// parseFactIdPath returns the FactId from the `{id}` path parameter. Reserved
+// FactIds are rejected, so they can't be modified through the API.
+func parseFactIdPath(req events.APIGatewayProxyRequest) (int, error) {
+	factId, err := strconv.Atoi(req.PathParameters["id"])
+	if err != nil {
+		return 0, err
+	}
+	if factId < 0 {
+		return 0, fmt.Errorf("fact id %d is reserved", factId)
+	}
+	return factId, nil
+}
+
+// allocateFactId atomically increments an animal's counter item and returns
+// the new value, so concurrent requests will never be handed the same FactId.
+func allocateFactId(ctx context.Context, animal string) (int, error) {
+	counterKey, err := marshalFactKey(animal, counterFactId)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := ddbClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key:       counterKey,
+		UpdateExpression: aws.String(
+			"SET LastFactId = if_not_exists(LastFactId, :start) + :one",
+		),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":start": &types.AttributeValueMemberN{
+				Value: strconv.Itoa(runtimeFactIdStart - 1),
+			},
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var counter struct {
+		LastFactId int `dynamodbav:"LastFactId"`
+	}
+	err = attributevalue.UnmarshalMap(result.Attributes, &counter)
+	if err != nil {
+		return 0, err
+	}
+
+	return counter.LastFactId, nil
+}
+
+// updateFactIndex builds the transaction item that records a fact being
+// created or deleted in the bookkeeping items, so that random selection stays
+// in step with the contents of the table.
+func updateFactIndex(animal string, factId int, created bool) (types.TransactWriteItem, error) {
+	// Runtime facts are tracked individually in the counter item, whereas
+	// seeded facts are only tracked once deleted, in the seed index.
+	indexFactId := counterFactId
+	updateExpression := "ADD FactIds :factIds"
+	if !created {
+		updateExpression = "DELETE FactIds :factIds"
+		if factId < runtimeFactIdStart {
+			indexFactId = seedIndexFactId
+			updateExpression = "ADD DeletedFactIds :factIds"
+		}
+	}
+
+	indexKey, err := marshalFactKey(animal, indexFactId)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+
+	return types.TransactWriteItem{
+		Update: &types.Update{
+			TableName:        aws.String(tableName),
+			Key:              indexKey,
+			UpdateExpression: aws.String(updateExpression),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":factIds": &types.AttributeValueMemberNS{
+					Value: []string{strconv.Itoa(factId)},
+				},
+			},
+		},
+	}, nil
+}
+
+// createFact writes a new fact to the table and records it in the counter
+// item, as a single transaction.
+func createFact(ctx context.Context, fact Fact) error {
+	item, err := attributevalue.MarshalMap(fact)
+	if err != nil {
+		return err
+	}
+
+	indexUpdate, err := updateFactIndex(fact.Animal, fact.FactId, true)
+	if err != nil {
+		return err
+	}
+
+	_, err = ddbClient.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:           aws.String(tableName),
+					Item:                item,
+					ConditionExpression: aws.String("attribute_not_exists(FactId)"),
+				},
+			},
+			indexUpdate,
+		},
+	})
+	return err
+}
+
+// updateFact overwrites an existing fact. The fact is only written if it
+// already exists.
+func updateFact(ctx context.Context, fact Fact) error {
+	item, err := attributevalue.MarshalMap(fact)
+	if err != nil {
+		return err
+	}
+
+	_, err = ddbClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_exists(FactId)"),
+	})
+	return err
+}
+
+// deleteFact removes a fact from the table and records the deletion in the
+// bookkeeping items, returning the deleted fact, or nil if it didn't exist.
+func deleteFact(ctx context.Context, animal string, factId int) (*Fact, error) {
+	fact, err := getFact(ctx, animal, factId)
+	if err != nil || fact == nil {
+		return nil, err
+	}
+
+	tableKey, err := marshalFactKey(animal, factId)
+	if err != nil {
+		return nil, err
+	}
+
+	indexUpdate, err := updateFactIndex(animal, factId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = ddbClient.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Delete: &types.Delete{
+					TableName:           aws.String(tableName),
+					Key:                 tableKey,
+					ConditionExpression: aws.String("attribute_exists(FactId)"),
+				},
+			},
+			indexUpdate,
+		},
+	})
+	// The fact was deleted by somebody else in the meantime.
+	if isConditionalCheckFailed(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return fact, nil
+}
+
+// isConditionalCheckFailed reports whether a write was rejected because of a
+// ConditionExpression, either directly or as part of a transaction.
+func isConditionalCheckFailed(err error) bool {
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		return true
+	}
+
+	var transactionCanceled *types.TransactionCanceledException
+	if errors.As(err, &transactionCanceled) {
+		for _, reason := range transactionCanceled.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func factResponse(status int, fact *Fact) (events.APIGatewayProxyResponse, error) {
+	json, err := json.Marshal(fact)
+	if err != nil {
+		log.Printf("Failed to json.Marshal(fact): %s", err)
+		return serverError(err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Body:       string(json),
+	}, nil
+}
+
+func processPost(ctx context.Context, animal string, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	factRequest, err := parseFactRequest(req)
+	if err != nil {
+		log.Printf("Invalid fact request: %s", err)
+		return clientError(http.StatusBadRequest)
+	}
+
+	factId, err := allocateFactId(ctx, animal)
+	if err != nil {
+		log.Printf("Failed to allocate fact id: %s", err)
+		return serverError(err)
+	}
+
+	fact := Fact{
+		Animal:       animal,
+		FactId:       factId,
+		Text:         factRequest.Text,
+		Lang:         factRequest.Lang,
+		Source:       factRequest.Source,
+		Tags:         factRequest.Tags,
+		Translations: factRequest.Translations,
+	}
+
+	err = createFact(ctx, fact)
+	if err != nil {
+		log.Printf("Failed to create '%s' fact %d: %s", animal, factId, err)
+		return serverError(err)
+	}
+	log.Printf("Successfully created '%s' fact %d", animal, factId)
+
+	return factResponse(http.StatusCreated, &fact)
+}
+
+func processPut(ctx context.Context, animal string, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	factId, err := parseFactIdPath(req)
+	if err != nil {
+		log.Printf("Invalid fact id: %s", err)
+		return clientError(http.StatusBadRequest)
+	}
+
+	factRequest, err := parseFactRequest(req)
+	if err != nil {
+		log.Printf("Invalid fact request: %s", err)
+		return clientError(http.StatusBadRequest)
+	}
+
+	fact := Fact{
+		Animal:       animal,
+		FactId:       factId,
+		Text:         factRequest.Text,
+		Lang:         factRequest.Lang,
+		Source:       factRequest.Source,
+		Tags:         factRequest.Tags,
+		Translations: factRequest.Translations,
+	}
+
+	err = updateFact(ctx, fact)
+	if isConditionalCheckFailed(err) {
+		return clientError(http.StatusNotFound)
+	}
+	if err != nil {
+		log.Printf("Failed to update '%s' fact %d: %s", animal, factId, err)
+		return serverError(err)
+	}
+	log.Printf("Successfully updated '%s' fact %d", animal, factId)
+
+	return factResponse(http.StatusOK, &fact)
+}
+
+func processDelete(ctx context.Context, animal string, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	factId, err := parseFactIdPath(req)
+	if err != nil {
+		log.Printf("Invalid fact id: %s", err)
+		return clientError(http.StatusBadRequest)
+	}
+
+	fact, err := deleteFact(ctx, animal, factId)
+	if err != nil {
+		log.Printf("Failed to delete '%s' fact %d: %s", animal, factId, err)
+		return serverError(err)
+	}
+
+	if fact == nil {
+		return clientError(http.StatusNotFound)
+	}
+	log.Printf("Successfully deleted '%s' fact %d", animal, factId)
+
+	return factResponse(http.StatusOK, fact)
+}
+
 func router(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Every route is scoped to an animal, e.g. `/otter/facts`.
+	animal := req.PathParameters["animal"]
+	if !isKnownAnimal(animal) {
+		return clientError(http.StatusNotFound)
+	}
+
 	switch req.HTTPMethod {
 	case "GET":
+		if strings.HasSuffix(req.Resource, "/today") {
+			return processGetToday(ctx, animal, req, getPreferredLanguages(req))
+		}
+
+		idsStr, ok := req.QueryStringParameters["ids"]
+		if ok {
+			return processBatchGet(ctx, animal, idsStr, getPreferredLanguages(req))
+		}
+
+		// Page through the facts if either of the listing parameters are
+		// supplied, otherwise return a single (specific or random) fact.
+		_, hasLimit := req.QueryStringParameters["limit"]
+		_, hasCursor := req.QueryStringParameters["cursor"]
+		if hasLimit || hasCursor {
+			return processList(ctx, animal, req, getPreferredLanguages(req))
+		}
+
 		factIdStr, ok := req.QueryStringParameters["FactId"]
 		factId, err := strconv.Atoi(factIdStr)
-		if err != nil || !ok {
-			factId = -1
+		if err != nil || !ok || factId < 0 {
+			return processGetRandom(ctx, animal, getPreferredLanguages(req))
+		}
+		return processGet(ctx, animal, factId, getPreferredLanguages(req))
+	case "POST", "PUT", "DELETE":
+		// The PAT has already been checked by the authorizer Lambda, which
+		// passes on its scopes.
+		scopes, ok := pattoken.ContextScopes(req.RequestContext.Authorizer)
+		if !ok {
+			return clientError(http.StatusUnauthorized)
+		}
+		if !pattoken.HasScope(scopes, pattoken.ScopeFactsWrite) {
+			return missingScope(pattoken.ScopeFactsWrite)
+		}
+
+		switch req.HTTPMethod {
+		case "POST":
+			return processPost(ctx, animal, req)
+		case "PUT":
+			return processPut(ctx, animal, req)
+		default:
+			return processDelete(ctx, animal, req)
 		}
-		return processGet(ctx, factId)
 	default:
 		return clientError(http.StatusMethodNotAllowed)
 	}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/base64"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+)
+
+func TestFactOfTheDayOrder(t *testing.T) {
+	factIds := []int{0, 1, 2, 3, 4, 7, 9}
+	start := time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		date  time.Time
+		other time.Time
+	}{
+		{"same day, different time", start, start.Add(23 * time.Hour)},
+		{"same calendar day, different timezone", start, time.Date(2023, time.June, 1, 23, 0, 0, 0, time.FixedZone("AEST", 10*60*60))},
+		{"before the epoch", time.Date(1969, time.December, 31, 0, 0, 0, 0, time.UTC), time.Date(1969, time.December, 31, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := factOfTheDayOrder("otter", factIds, test.date)
+			other := factOfTheDayOrder("otter", factIds, test.other)
+			if !reflect.DeepEqual(got, other) {
+				t.Errorf("factOfTheDayOrder(%s) = %v, but factOfTheDayOrder(%s) = %v", test.date, got, test.other, other)
+			}
+
+			// Every fact is listed exactly once.
+			sorted := append([]int{}, got...)
+			sort.Ints(sorted)
+			if !reflect.DeepEqual(sorted, factIds) {
+				t.Errorf("factOfTheDayOrder(%s) = %v, want a permutation of %v", test.date, got, factIds)
+			}
+		})
+	}
+}
+
+func TestFactOfTheDayOrderCycle(t *testing.T) {
+	factIds := []int{0, 1, 2, 3, 4}
+
+	// Each cycle starts on a day that's a multiple of the number of facts
+	// since the epoch, and shows every fact exactly once.
+	start := time.Unix(int64(len(factIds))*10000*86400, 0).UTC()
+	seen := map[int]bool{}
+	for day := 0; day < len(factIds); day++ {
+		order := factOfTheDayOrder("otter", factIds, start.AddDate(0, 0, day))
+		if seen[order[0]] {
+			t.Errorf("fact %d was picked twice in a cycle", order[0])
+		}
+		seen[order[0]] = true
+
+		// The facts after the day's own are those of the following days.
+		if day > 0 {
+			previous := factOfTheDayOrder("otter", factIds, start.AddDate(0, 0, day-1))
+			if previous[1] != order[0] {
+				t.Errorf("day %d picked %d, but the day before listed %d next", day, order[0], previous[1])
+			}
+		}
+	}
+
+	// The order of the FactIds passed in doesn't matter, nor is the slice
+	// changed.
+	reversed := []int{4, 3, 2, 1, 0}
+	got := factOfTheDayOrder("otter", reversed, start)
+	want := factOfTheDayOrder("otter", factIds, start)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("factOfTheDayOrder(%v) = %v, want %v", reversed, got, want)
+	}
+	if !reflect.DeepEqual(reversed, []int{4, 3, 2, 1, 0}) {
+		t.Errorf("factOfTheDayOrder() changed its argument to %v", reversed)
+	}
+
+	if got := factOfTheDayOrder("otter", nil, start); len(got) != 0 {
+		t.Errorf("factOfTheDayOrder(nil) = %v, want nothing", got)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	lastEvaluatedKey, err := marshalFactKey("otter", 42)
+	if err != nil {
+		t.Fatalf("marshalFactKey() returned an error: %s", err)
+	}
+	cursor, err := encodeCursor(lastEvaluatedKey)
+	if err != nil {
+		t.Fatalf("encodeCursor() returned an error: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		animal  string
+		cursor  string
+		want    factKey
+		wantErr bool
+	}{
+		{"round trip", "otter", cursor, factKey{Animal: "otter", FactId: 42}, false},
+		{"another animal", "platypus", cursor, factKey{}, true},
+		{"not base64", "otter", "not a cursor!", factKey{}, true},
+		{"not json", "otter", base64.RawURLEncoding.EncodeToString([]byte("otter/42")), factKey{}, true},
+		{"empty", "otter", "", factKey{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			startKey, err := decodeCursor(test.animal, test.cursor)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("decodeCursor(%q) = %v, want an error", test.cursor, startKey)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeCursor(%q) returned an error: %s", test.cursor, err)
+			}
+
+			var got factKey
+			err = attributevalue.UnmarshalMap(startKey, &got)
+			if err != nil {
+				t.Fatalf("UnmarshalMap() returned an error: %s", err)
+			}
+			if got != test.want {
+				t.Errorf("decodeCursor(%q) = %+v, want %+v", test.cursor, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"fr", []string{"fr"}},
+		{"fr-CA,fr;q=0.9,en;q=0.8", []string{"fr-ca", "fr", "en"}},
+		{"en;q=0.5, de", []string{"de", "en"}},
+		{"fr;q=0.8,de;q=0.8", []string{"fr", "de"}},
+		{"*, fr;q=0.5", []string{"fr"}},
+		{"fr;q=0,en", []string{"en"}},
+		{"fr;q=high", []string{"fr"}},
+	}
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			got := parseAcceptLanguage(test.header)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseAcceptLanguage(%q) = %v, want %v", test.header, got, test.want)
+			}
+		})
+	}
+}
+
+func TestLocaliseFact(t *testing.T) {
+	tests := []struct {
+		name      string
+		fact      Fact
+		languages []string
+		wantLang  string
+		wantText  string
+	}{
+		{"default", Fact{Text: "Otter"}, nil, "en", "Otter"},
+		{"exact match", Fact{Text: "Otter", Lang: "en", Translations: map[string]string{"fr": "Loutre"}}, []string{"fr"}, "fr", "Loutre"},
+		{"primary language", Fact{Text: "Otter", Lang: "en", Translations: map[string]string{"fr": "Loutre"}}, []string{"fr-ca"}, "fr", "Loutre"},
+		{"regional variant", Fact{Text: "Otter", Lang: "en", Translations: map[string]string{"pt": "Lontra", "pt-BR": "Ariranha"}}, []string{"pt-br"}, "pt-br", "Ariranha"},
+		{"preference order", Fact{Text: "Otter", Lang: "en", Translations: map[string]string{"fr": "Loutre", "de": "Otter"}}, []string{"de", "fr"}, "de", "Otter"},
+		{"unavailable", Fact{Text: "Otter", Lang: "en", Translations: map[string]string{"fr": "Loutre"}}, []string{"ja"}, "en", "Otter"},
+		{"english fallback", Fact{Text: "Loutre", Lang: "fr", Translations: map[string]string{"en": "Otter"}}, []string{"ja"}, "en", "Otter"},
+		{"as written", Fact{Text: "Loutre", Lang: "fr"}, []string{"ja"}, "fr", "Loutre"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fact := test.fact
+			localiseFact(&fact, test.languages)
+			if fact.Lang != test.wantLang || fact.Text != test.wantText {
+				t.Errorf("localiseFact(%v) = %s %q, want %s %q", test.languages, fact.Lang, fact.Text, test.wantLang, test.wantText)
+			}
+			if fact.Translations != nil {
+				t.Errorf("localiseFact(%v) left the translations in place", test.languages)
+			}
+		})
+	}
+}
+
+func TestParseFactIds(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     string
+		want    []int
+		wantErr bool
+	}{
+		{"single", "5", []int{5}, false},
+		{"several", "1,5,9", []int{1, 5, 9}, false},
+		{"spaces", " 1, 5 ,9", []int{1, 5, 9}, false},
+		{"repeated", "5,5", []int{5, 5}, false},
+		// Reserved FactIds are never fetched, so they're returned as null.
+		{"negative", "-1,2", []int{-1, 2}, false},
+		{"empty", "", nil, true},
+		{"trailing comma", "1,", nil, true},
+		{"not a number", "1,two", nil, true},
+		{"at the limit", strings.Repeat("1,", batchGetLimitMax-1) + "1", nil, false},
+		{"over the limit", strings.Repeat("1,", batchGetLimitMax) + "1", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseFactIds(test.ids)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("parseFactIds(%q) = %v, want an error", test.ids, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFactIds(%q) returned an error: %s", test.ids, err)
+			}
+			if test.want != nil && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseFactIds(%q) = %v, want %v", test.ids, got, test.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,34 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
 	"log"
 	"math/rand"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -15,29 +36,249 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"cdn"
+	"pattoken"
 )
 
 const bucketNameEnvVar = string("IMAGES_BUCKET_NAME")
 const bucketNameDefault = string("xaas-api-assets")
 const objectKeyPrefixEnvVar = string("IMAGES_OBJECT_PREFIX")
-const objectKeyPrefixDefault = string("animals/animal/images/")
+const objectKeyPrefixDefault = string("animals/{animal}/images/")
+const objectKeyPrefixPlaceholder = string("{animal}")
 const objectPublicUrlTemplate = string("https://%s.s3.amazonaws.com/%s")
+const animalsEnvVar = string("ANIMALS")
+const animalsDefault = string("animal")
+
+// The key of the manifest written at deploy time, listing each animal's images
+// and their tags. If it isn't set, or the manifest can't be read, the images
+// are found by listing the bucket instead.
+const manifestKeyEnvVar = string("IMAGES_MANIFEST_KEY")
+
+// How long an animal's image index is cached for, across warm invocations,
+// before the manifest (or bucket) is read again.
+const imageIndexTtlEnvVar = string("IMAGES_INDEX_TTL_SECONDS")
+const imageIndexTtlDefault = 5 * time.Minute
+
+// In a private bucket, images are served through presigned URLs that expire
+// after presignExpiry, rather than through their public URLs.
+const bucketAccessEnvVar = string("IMAGES_BUCKET_ACCESS")
+const bucketAccessPrivate = string("private")
+const presignExpiryEnvVar = string("IMAGES_PRESIGN_EXPIRY_SECONDS")
+const presignExpiryDefault = 15 * time.Minute
+
+// S3 refuses presigned URLs that last for more than a week.
+const presignExpiryMax = 7 * 24 * time.Hour
+
+// Resized images are written back to the bucket under the derived prefix, so
+// each variant only has to be generated once. Variants are keyed by the sha256
+// of the original image, rather than its name, so a variant can never be
+// served for a different image of the same name.
+const derivedKeyPrefixEnvVar = string("IMAGES_DERIVED_PREFIX")
+const derivedKeyPrefixDefault = string("derived/animals/{animal}/images/")
+
+// Images can't be resized beyond resizeWidthMax pixels wide, which also keeps
+// the base64-encoded response well within Lambda's payload limit.
+const resizeWidthMax = int(2048)
+const resizeJpegQuality = int(85)
+const resizeCacheControl = string("public, max-age=86400")
+
+// Images can be listed a page at a time, rather than picked at random.
+const listLimitDefault = int(25)
+const listLimitMax = int(100)
+
+// Images can be filtered by their tags, e.g. `?tag.attribution=Adobe%20Stock`.
+// The tags written from `metadata.json` can also be filtered on without the
+// prefix, e.g. `?source=...`.
+const tagFilterPrefix = string("tag.")
+
+var tagFilterShorthands = []string{"attribution", "description", "source"}
+
+// Images uploaded through the API are recorded in a second manifest, which is
+// only ever written by this Lambda, so that a later deploy doesn't forget them.
+const uploadsManifestKeyEnvVar = string("IMAGES_UPLOADS_MANIFEST_KEY")
+
+// Uploaded images must be no bigger than uploadSizeMax bytes, which keeps the
+// (base64-encoded) request within Lambda's payload limit, and no more than
+// uploadDimensionMax pixels wide or high.
+const uploadSizeMax = int(4 << 20)
+const uploadDimensionMax = int(4096)
+
+// S3 limits the length of tag values, and the characters they may contain. The
+// same rules are applied to the deployed images' `metadata.json` by the IaC.
+const uploadTagLengthMax = int(256)
+
+var uploadNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+var uploadTagPattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// The content types that can be uploaded, and the file extensions that images
+// of each type must be named with.
+var uploadContentTypes = map[string][]string{
+	"image/jpeg": {".jpeg", ".jpg"},
+	"image/png":  {".png"},
+}
 
 var s3Client s3.Client
+var s3PresignClient *s3.PresignClient
+var uploadsManifestKey string
+var animals []string
+var bucketName string
+var objectKeyPrefix string
+var manifestKey string
+var derivedKeyPrefix string
+var imageIndexTtl time.Duration
+var bucketPrivate bool
+var presignExpiry time.Duration
+
+// Behind the stack's CloudFront distribution, images are served through the
+// distribution instead. Its domain name is read from the stack's parameter,
+// rather than taken from the request, so clients can't choose the domain of
+// the URLs handed out.
+var cdnDomain *cdn.Domain
+
+// The cached image index of each animal, keyed by the animal's name.
+var imageIndexes = map[string]*imageIndex{}
+var imageIndexesLock sync.Mutex
 
 type Image struct {
+	Name string    `json:"name"`
 	Url  string    `json:"url"`
 	Tags ImageTags `json:"tags"`
+	ImageDetails
+}
+
+// ImageDetails are read from each image when it's deployed or uploaded, and
+// stored in the manifest and as the image's S3 object metadata. They're
+// omitted for images that predate them.
+type ImageDetails struct {
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Format string `json:"format,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	Sha256 string `json:"sha256,omitempty"`
 }
 
 type ImageTags map[string]string
 
+// ImagePage is a single page of images returned by the listing endpoint.
+// Cursor is an opaque token that can be passed back to fetch the following
+// page, and Next is a ready-made link to that page. Both are omitted on the
+// last page.
+type ImagePage struct {
+	Images []Image `json:"images"`
+	Cursor string  `json:"cursor,omitempty"`
+	Next   string  `json:"next,omitempty"`
+}
+
+// ImageManifest mirrors the manifest written by the IaC at deploy time.
+type ImageManifest struct {
+	Images []ImageManifestEntry `json:"images"`
+}
+
+// ImageManifestEntry is a single image in the manifest. Name is the image's
+// file name, which deployed images are no longer stored under, as their keys
+// are derived from their contents. Tags is nil for images found by listing the
+// bucket, in which case they are fetched from S3 once the image has been
+// picked. The same goes for the image's details.
+type ImageManifestEntry struct {
+	Name string    `json:"name"`
+	Key  string    `json:"key"`
+	Tags ImageTags `json:"tags"`
+	ImageDetails
+
+	detailsLoaded bool
+}
+
+// ImageUpload is the JSON body of an upload request. Image holds the
+// base64-encoded image.
+type ImageUpload struct {
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	Source      string `json:"source"`
+	Attribution string `json:"attribution"`
+	Description string `json:"description"`
+}
+
+// imageIndex is the cached list of an animal's images.
+type imageIndex struct {
+	Images  []ImageManifestEntry
+	Expires time.Time
+}
+
 func init() {
 	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
 		log.Fatal(err)
 	}
 	s3Client = *s3.NewFromConfig(sdkConfig)
+	cdnDomain = cdn.New(sdkConfig)
+	s3PresignClient = s3.NewPresignClient(&s3Client)
+
+	// Grab the comma-separated list of animals served by this stack from the
+	// environment variables. If the environment variable is not defined, fall
+	// back to a default.
+	animalList := os.Getenv(animalsEnvVar)
+	if len(animalList) == 0 {
+		animalList = animalsDefault
+	}
+	animals = strings.Split(animalList, ",")
+
+	// Grab the name of the image bucket from the environment variables.
+	// If the environment variable is not defined, fall back to a default.
+	bucketName = os.Getenv(bucketNameEnvVar)
+	if len(bucketName) == 0 {
+		bucketName = bucketNameDefault
+	}
+
+	// Grab the key prefix for the objects in the image bucket from the
+	// environment variables. If the environment variable is not defined, fall
+	// back to a default.
+	objectKeyPrefix = os.Getenv(objectKeyPrefixEnvVar)
+	if len(objectKeyPrefix) == 0 {
+		objectKeyPrefix = objectKeyPrefixDefault
+	}
+
+	derivedKeyPrefix = os.Getenv(derivedKeyPrefixEnvVar)
+	if len(derivedKeyPrefix) == 0 {
+		derivedKeyPrefix = derivedKeyPrefixDefault
+	}
+
+	// The manifests are optional, so there are no defaults.
+	manifestKey = os.Getenv(manifestKeyEnvVar)
+	uploadsManifestKey = os.Getenv(uploadsManifestKeyEnvVar)
+
+	imageIndexTtl = imageIndexTtlDefault
+	ttlStr := os.Getenv(imageIndexTtlEnvVar)
+	if len(ttlStr) > 0 {
+		ttlSeconds, err := strconv.Atoi(ttlStr)
+		if err != nil || ttlSeconds < 0 {
+			log.Fatalf("Invalid %s '%s'", imageIndexTtlEnvVar, ttlStr)
+		}
+		imageIndexTtl = time.Duration(ttlSeconds) * time.Second
+	}
+
+	// The bucket is public unless the stack says otherwise.
+	bucketPrivate = os.Getenv(bucketAccessEnvVar) == bucketAccessPrivate
+
+	presignExpiry = presignExpiryDefault
+	expiryStr := os.Getenv(presignExpiryEnvVar)
+	if len(expiryStr) > 0 {
+		expirySeconds, err := strconv.Atoi(expiryStr)
+		if err != nil || expirySeconds <= 0 {
+			log.Fatalf("Invalid %s '%s'", presignExpiryEnvVar, expiryStr)
+		}
+		presignExpiry = time.Duration(expirySeconds) * time.Second
+		if presignExpiry > presignExpiryMax {
+			log.Printf("%s '%s' is longer than S3 allows, using %s", presignExpiryEnvVar, expiryStr, presignExpiryMax)
+			presignExpiry = presignExpiryMax
+		}
+	}
 }
 
 func clientError(status int) (events.APIGatewayProxyResponse, error) {
@@ -47,6 +288,15 @@ func clientError(status int) (events.APIGatewayProxyResponse, error) {
 	}, nil
 }
 
+// missingScope is returned when a valid PAT hasn't been granted the scope that
+// the route needs.
+func missingScope(scope string) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{
+		Body:       fmt.Sprintf("%s: the PAT doesn't have the '%s' scope", http.StatusText(http.StatusForbidden), scope),
+		StatusCode: http.StatusForbidden,
+	}, nil
+}
+
 func serverError(err error) (events.APIGatewayProxyResponse, error) {
 	return events.APIGatewayProxyResponse{
 		Body:       http.StatusText(http.StatusInternalServerError),
@@ -54,50 +304,163 @@ func serverError(err error) (events.APIGatewayProxyResponse, error) {
 	}, nil
 }
 
-func getImage(ctx context.Context) (*Image, error) {
-	// Grab the name of the image bucket from the environment variables.
-	// If the environment variable is not defined, fall back to a default.
-	bucketName := os.Getenv(bucketNameEnvVar)
-	if len(bucketName) == 0 {
-		bucketName = bucketNameDefault
+// isKnownAnimal reports whether the stack serves images of an animal.
+func isKnownAnimal(animal string) bool {
+	for _, knownAnimal := range animals {
+		if knownAnimal == animal {
+			return true
+		}
 	}
+	return false
+}
 
-	// Grab the key prefix for the objects in the image bucket from the
-	// environment variables. If the environment variable is not defined, fall
-	// back to a default.
-	objectKeyPrefix := os.Getenv(objectKeyPrefixEnvVar)
-	if len(objectKeyPrefix) == 0 {
-		objectKeyPrefix = objectKeyPrefixDefault
-	}
+// forAnimal substitutes the animal's name into a key template, as each
+// animal's images are stored under their own prefix.
+func forAnimal(template string, animal string) string {
+	return strings.ReplaceAll(template, objectKeyPrefixPlaceholder, animal)
+}
 
-	// Get a random image
-	// Count the number of images.
-	objects, err := s3Client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
+// loadManifest reads an animal's images from a manifest, either the one
+// written at deploy time or the one listing uploaded images.
+func loadManifest(ctx context.Context, key string) ([]ImageManifestEntry, error) {
+	object, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(bucketName),
-		Prefix: aws.String(objectKeyPrefix),
+		Key:    aws.String(key),
 	})
 	if err != nil {
 		return nil, err
 	}
+	defer object.Body.Close()
 
-	imageId := 0
-	imageCount := int(objects.KeyCount)
-	if imageCount <= 0 {
-		return nil, fmt.Errorf(
-			"no images found in image bucket %s w/ prefix %s",
-			bucketName,
-			objectKeyPrefix,
-		)
-	} else if imageCount > 1 {
-		imageId = rand.Intn(imageCount - 1)
+	var manifest ImageManifest
+	err = json.NewDecoder(object.Body).Decode(&manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// The manifest holds every image's tags and details, so there's no need
+	// to fetch them again later.
+	for i := range manifest.Images {
+		if manifest.Images[i].Tags == nil {
+			manifest.Images[i].Tags = ImageTags{}
+		}
+		manifest.Images[i].detailsLoaded = true
+	}
+
+	return manifest.Images, nil
+}
+
+// listImages finds an animal's images by listing every page of objects under
+// the animal's prefix.
+func listImages(ctx context.Context, animal string) ([]ImageManifestEntry, error) {
+	images := make([]ImageManifestEntry, 0)
+	paginator := s3.NewListObjectsV2Paginator(&s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(forAnimal(objectKeyPrefix, animal)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			// Without the manifest, the key is the best name available.
+			images = append(images, ImageManifestEntry{
+				Name: strings.TrimPrefix(*object.Key, forAnimal(objectKeyPrefix, animal)),
+				Key:  *object.Key,
+				ImageDetails: ImageDetails{
+					Size: object.Size,
+				},
+			})
+		}
+	}
+
+	return images, nil
+}
+
+// getImageIndex returns the list of an animal's images, from the cache if it
+// hasn't expired. Otherwise the manifest is read, falling back to listing the
+// bucket if there isn't one.
+func getImageIndex(ctx context.Context, animal string) ([]ImageManifestEntry, error) {
+	imageIndexesLock.Lock()
+	defer imageIndexesLock.Unlock()
+
+	index, ok := imageIndexes[animal]
+	if ok && time.Now().Before(index.Expires) {
+		return index.Images, nil
+	}
+
+	var images []ImageManifestEntry
+	var err error
+	if len(manifestKey) > 0 {
+		images, err = loadManifest(ctx, forAnimal(manifestKey, animal))
+		if err != nil {
+			log.Printf("Failed to load the '%s' image manifest, listing the bucket instead: %s", animal, err)
+		}
+	}
+	if images != nil && len(uploadsManifestKey) > 0 {
+		uploads, err := loadUploadsManifest(ctx, animal)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, uploads...)
+	}
+	if images == nil {
+		images, err = listImages(ctx, animal)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The images are kept in key order, so that they can be paged through.
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Key < images[j].Key
+	})
+
+	// An empty index isn't cached, so that newly added images are picked up
+	// straight away.
+	if len(images) > 0 {
+		imageIndexes[animal] = &imageIndex{
+			Images:  images,
+			Expires: time.Now().Add(imageIndexTtl),
+		}
+	}
+
+	return images, nil
+}
+
+// parseTagFilters returns the tags that images must have to be returned,
+// from the `tag.` prefixed (and shorthand) query parameters.
+func parseTagFilters(query map[string]string) ImageTags {
+	filters := ImageTags{}
+	for name, value := range query {
+		if strings.HasPrefix(name, tagFilterPrefix) {
+			filters[strings.TrimPrefix(name, tagFilterPrefix)] = value
+		}
+	}
+	for _, name := range tagFilterShorthands {
+		value, ok := query[name]
+		if ok {
+			filters[name] = value
+		}
+	}
+	return filters
+}
+
+// getImageTags returns an image's tags. Images found by listing the bucket
+// don't have their tags until they're first needed, at which point they're
+// fetched from S3 and kept in the cached index.
+func getImageTags(ctx context.Context, image *ImageManifestEntry) (ImageTags, error) {
+	if image.Tags != nil {
+		return image.Tags, nil
 	}
 
 	getTagsInput := &s3.GetObjectTaggingInput{
 		Bucket: aws.String(bucketName),
-		Key:    objects.Contents[imageId].Key,
+		Key:    aws.String(image.Key),
 	}
 
-	rawTags, err := s3Client.GetObjectTagging(context.TODO(), getTagsInput)
+	rawTags, err := s3Client.GetObjectTagging(ctx, getTagsInput)
 	if err != nil {
 		return nil, err
 	}
@@ -106,29 +469,263 @@ func getImage(ctx context.Context) (*Image, error) {
 	for _, tag := range rawTags.TagSet {
 		tags[*tag.Key] = *tag.Value
 	}
+	image.Tags = tags
+
+	return tags, nil
+}
+
+// getImageDetails returns an image's details. As with tags, the details of
+// images found by listing the bucket are fetched from the object's metadata
+// when they're first needed.
+func getImageDetails(ctx context.Context, image *ImageManifestEntry) (ImageDetails, error) {
+	if image.detailsLoaded {
+		return image.ImageDetails, nil
+	}
+
+	object, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(image.Key),
+	})
+	if err != nil {
+		return ImageDetails{}, err
+	}
+
+	// S3 lower-cases the metadata keys. Images that predate the details
+	// won't have them, so anything missing is left out.
+	image.Width, _ = strconv.Atoi(object.Metadata["width"])
+	image.Height, _ = strconv.Atoi(object.Metadata["height"])
+	image.Format = object.Metadata["format"]
+	image.Size = object.ContentLength
+	image.Sha256 = object.Metadata["sha256"]
+	image.detailsLoaded = true
+
+	return image.ImageDetails, nil
+}
+
+// filterImages returns the images that have every one of the filtered tags.
+// Tag values are compared case-insensitively.
+func filterImages(ctx context.Context, images []ImageManifestEntry, filters ImageTags) ([]ImageManifestEntry, error) {
+	if len(filters) == 0 {
+		return images, nil
+	}
+
+	matches := make([]ImageManifestEntry, 0)
+	for i := range images {
+		tags, err := getImageTags(ctx, &images[i])
+		if err != nil {
+			return nil, err
+		}
+
+		matched := true
+		for name, value := range filters {
+			if !strings.EqualFold(tags[name], value) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, images[i])
+		}
+	}
+
+	return matches, nil
+}
+
+// toImage turns an entry of the image index into the image returned to
+// clients.
+func toImage(ctx context.Context, entry *ImageManifestEntry) (*Image, error) {
+	tags, err := getImageTags(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := getImageDetails(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := getImageUrl(ctx, entry.Key)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Image{
-		Url: fmt.Sprintf(
-			objectPublicUrlTemplate,
-			bucketName,
-			*objects.Contents[imageId].Key,
-		),
-		Tags: tags,
+		Name:         entry.Name,
+		Url:          url,
+		Tags:         tags,
+		ImageDetails: details,
 	}, nil
 }
 
-func processGet(ctx context.Context) (events.APIGatewayProxyResponse, error) {
-	image, err := getImage(ctx)
+// getImage returns a random image with the filtered tags, or nil if none of
+// the animal's images have them.
+func getImage(ctx context.Context, animal string, filters ImageTags) (*Image, error) {
+	images, err := getImageIndex(ctx, animal)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(images) == 0 {
+		return nil, fmt.Errorf(
+			"no images found in image bucket %s w/ prefix %s",
+			bucketName,
+			forAnimal(objectKeyPrefix, animal),
+		)
+	}
+
+	images, err = filterImages(ctx, images, filters)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, nil
+	}
+
+	// Get a random image
+	return toImage(ctx, &images[rand.Intn(len(images))])
+}
+
+// getCdnDomain returns the domain name of the stack's distribution, or an
+// empty string if it has none.
+func getCdnDomain(ctx context.Context) string {
+	domain, err := cdnDomain.Get(ctx)
+	if err != nil {
+		log.Printf("Failed to get the CDN domain: %s", err)
+	}
+	return domain
+}
+
+// getImageUrl returns the URL that an image can be downloaded from. Stacks
+// with a CloudFront distribution give out the image's URL on the distribution.
+// Otherwise, images in a private bucket are given a presigned URL, which
+// expires after presignExpiry.
+func getImageUrl(ctx context.Context, key string) (string, error) {
+	domain := getCdnDomain(ctx)
+	if len(domain) > 0 {
+		return fmt.Sprintf("https://%s/%s", domain, key), nil
+	}
+	if !bucketPrivate {
+		return fmt.Sprintf(objectPublicUrlTemplate, bucketName, key), nil
+	}
+
+	request, err := s3PresignClient.PresignGetObject(
+		ctx,
+		&s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		},
+		s3.WithPresignExpires(presignExpiry),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return request.URL, nil
+}
+
+// wantsRedirect reports whether the client asked to be redirected to a random
+// image, rather than be sent its details, e.g. from an `<img src>`. Clients
+// ask either with `?redirect=true`, or by preferring an image type to HTML,
+// JSON and `*/*`. Browsers navigating to the endpoint accept images as readily
+// as HTML, so they're sent JSON.
+func wantsRedirect(req events.APIGatewayProxyRequest) bool {
+	if req.QueryStringParameters["redirect"] == "true" {
+		return true
+	}
+	accept, ok := getHeader(req.Headers, "Accept")
+	if !ok {
+		return false
+	}
+
+	qualities := parseAccept(accept)
+	imageQuality := 0.0
+	for mediaType, quality := range qualities {
+		if strings.HasPrefix(mediaType, "image/") && quality > imageQuality {
+			imageQuality = quality
+		}
+	}
+	for _, mediaType := range []string{"text/html", "application/json", "*/*"} {
+		if qualities[mediaType] >= imageQuality {
+			return false
+		}
+	}
+	return true
+}
+
+// parseAccept parses an Accept header value, such as
+// `text/html,image/*;q=0.8`, into the quality value of each lower-case media
+// range. A media range listed more than once keeps its highest quality.
+func parseAccept(header string) map[string]float64 {
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if len(mediaType) == 0 {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.TrimSpace(key) == "q" {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err == nil {
+					quality = parsed
+				}
+			}
+		}
+
+		if quality > qualities[mediaType] {
+			qualities[mediaType] = quality
+		}
+	}
+	return qualities
+}
+
+// getETag returns a strong entity tag for a response body.
+func getETag(body []byte) string {
+	digest := sha256.Sum256(body)
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(digest[:16]))
+}
+
+// etagMatches reports whether an If-None-Match header value matches an entity
+// tag. Weak comparison is used, as If-None-Match requires.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func processGet(ctx context.Context, animal string, filters ImageTags, redirect bool, ifNoneMatch string) (events.APIGatewayProxyResponse, error) {
+	image, err := getImage(ctx, animal, filters)
 	if err != nil {
 		log.Printf("Failed to get image: %s", err)
 		return serverError(err)
 	}
 
 	if image == nil {
-		log.Printf("nil returned from getImage!")
+		log.Printf("No images matched the filters: %v", filters)
 		return clientError(http.StatusNotFound)
 	}
 
+	// Each load of a redirect should pick a fresh image, so it mustn't be
+	// cached.
+	if redirect {
+		log.Printf("Redirecting to image: %s", image.Url)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusFound,
+			Headers: map[string]string{
+				"Location":      image.Url,
+				"Cache-Control": "no-store",
+				"Vary":          "Accept",
+			},
+		}, nil
+	}
+
 	json, err := json.Marshal(image)
 	if err != nil {
 		log.Printf("Failed to json.Marshal(image): %s", err)
@@ -136,16 +733,703 @@ func processGet(ctx context.Context) (events.APIGatewayProxyResponse, error) {
 	}
 	log.Printf("Successfully fetched image: %s", json)
 
+	// Clients that already hold the image's details are told so, rather than
+	// being sent them again.
+	headers := map[string]string{
+		"ETag": getETag(json),
+		"Vary": "Accept",
+	}
+	if len(ifNoneMatch) > 0 && etagMatches(ifNoneMatch, headers["ETag"]) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNotModified,
+			Headers:    headers,
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       string(json),
+	}, nil
+}
+
+// encodeCursor turns the key of the last image on a page into an opaque,
+// URL-safe token.
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// decodeCursor reverses encodeCursor.
+func decodeCursor(token string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
+
+// nextLink builds the link to the page following the current one, keeping the
+// filters of the current page. The stage name is included, as API Gateway
+// strips it from the request path.
+func nextLink(ctx context.Context, req events.APIGatewayProxyRequest, limit int, cursor string) string {
+	query := url.Values{}
+	for name, value := range req.QueryStringParameters {
+		query.Set(name, value)
+	}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("cursor", cursor)
+
+	// The distribution adds the stage to the path itself.
+	domain := getCdnDomain(ctx)
+	if len(domain) > 0 {
+		return fmt.Sprintf("https://%s%s?%s", domain, req.Path, query.Encode())
+	}
+
+	path := req.Path
+	if len(req.RequestContext.Stage) > 0 {
+		path = fmt.Sprintf("/%s%s", req.RequestContext.Stage, path)
+	}
+
+	host, ok := getHeader(req.Headers, "Host")
+	if !ok {
+		return fmt.Sprintf("%s?%s", path, query.Encode())
+	}
+	return fmt.Sprintf("https://%s%s?%s", host, path, query.Encode())
+}
+
+func processList(ctx context.Context, animal string, req events.APIGatewayProxyRequest, filters ImageTags) (events.APIGatewayProxyResponse, error) {
+	limit := listLimitDefault
+	limitStr, ok := req.QueryStringParameters["limit"]
+	if ok {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return clientError(http.StatusBadRequest)
+		}
+		if limit > listLimitMax {
+			limit = listLimitMax
+		}
+	}
+
+	// The page starts after the key in the cursor, so it's unaffected by
+	// images being added or removed when the index is refreshed.
+	after := ""
+	cursorStr, ok := req.QueryStringParameters["cursor"]
+	if ok && len(cursorStr) > 0 {
+		var err error
+		after, err = decodeCursor(cursorStr)
+		if err != nil {
+			log.Printf("Failed to decode cursor '%s': %s", cursorStr, err)
+			return clientError(http.StatusBadRequest)
+		}
+	}
+
+	images, err := getImageIndex(ctx, animal)
+	if err != nil {
+		log.Printf("Failed to get image index: %s", err)
+		return serverError(err)
+	}
+
+	start := sort.Search(len(images), func(i int) bool {
+		return images[i].Key > after
+	})
+	images, err = filterImages(ctx, images[start:], filters)
+	if err != nil {
+		log.Printf("Failed to filter images: %s", err)
+		return serverError(err)
+	}
+
+	page := ImagePage{
+		Images: make([]Image, 0, limit),
+	}
+	for i := 0; i < len(images) && i < limit; i++ {
+		image, err := toImage(ctx, &images[i])
+		if err != nil {
+			log.Printf("Failed to get image: %s", err)
+			return serverError(err)
+		}
+		page.Images = append(page.Images, *image)
+	}
+
+	if len(images) > limit {
+		page.Cursor = encodeCursor(images[limit-1].Key)
+		page.Next = nextLink(ctx, req, limit, page.Cursor)
+	}
+
+	json, err := json.Marshal(page)
+	if err != nil {
+		log.Printf("Failed to json.Marshal(page): %s", err)
+		return serverError(err)
+	}
+	log.Printf("Successfully listed %d '%s' images", len(page.Images), animal)
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(json),
+	}, nil
+}
+
+// imageFormats maps the formats that images can be converted to onto their
+// content types.
+var imageFormats = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+}
+
+// formatFromName guesses an image's format from its file extension, so that
+// images are served in their original format unless asked otherwise.
+func formatFromName(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".png":
+		return "png"
+	default:
+		return "jpeg"
+	}
+}
+
+// findImage returns one of an animal's images, given its name. Images can also
+// be found by the name of the object they're stored as.
+func findImage(ctx context.Context, animal string, name string) (ImageManifestEntry, bool, error) {
+	images, err := getImageIndex(ctx, animal)
+	if err != nil {
+		return ImageManifestEntry{}, false, err
+	}
+
+	key := forAnimal(objectKeyPrefix, animal) + name
+	for _, image := range images {
+		if image.Name == name || image.Key == key {
+			return image, true, nil
+		}
+	}
+	return ImageManifestEntry{}, false, nil
+}
+
+// getObject reads the whole of an object from the image bucket. The returned
+// bool is false if the object doesn't exist.
+func getObject(ctx context.Context, key string) ([]byte, bool, error) {
+	object, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer object.Body.Close()
+
+	body, err := io.ReadAll(object.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	return body, true, nil
+}
+
+// resizeImage scales an image down to the given width, keeping its aspect
+// ratio, and encodes it in the given format. Images are never scaled up, and a
+// width of 0 keeps the original size. Transparent areas are filled with white,
+// as JPEGs have no alpha channel.
+func resizeImage(original []byte, width int, format string) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	if width <= 0 || width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+	height := (bounds.Dy()*width + bounds.Dx()/2) / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if format == "jpeg" {
+		draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var encoded bytes.Buffer
+	switch format {
+	case "png":
+		err = png.Encode(&encoded, dst)
+	default:
+		err = jpeg.Encode(&encoded, dst, &jpeg.Options{Quality: resizeJpegQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return encoded.Bytes(), nil
+}
+
+// processGetResized returns one of an animal's images, resized to the `w`
+// query parameter and converted to the `format` query parameter. Each variant
+// is written back to the bucket the first time it's requested, and read from
+// there afterwards.
+func processGetResized(ctx context.Context, animal string, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	name := req.PathParameters["name"]
+	image, found, err := findImage(ctx, animal, name)
+	if err != nil {
+		log.Printf("Failed to find image: %s", err)
+		return serverError(err)
+	}
+	if !found {
+		return clientError(http.StatusNotFound)
+	}
+	key := image.Key
+
+	width := 0
+	widthStr, ok := req.QueryStringParameters["w"]
+	if ok {
+		width, err = strconv.Atoi(widthStr)
+		if err != nil || width <= 0 || width > resizeWidthMax {
+			return clientError(http.StatusBadRequest)
+		}
+	}
+
+	format := formatFromName(name)
+	formatStr, ok := req.QueryStringParameters["format"]
+	if ok {
+		format = strings.ToLower(formatStr)
+		if format == "jpg" {
+			format = "jpeg"
+		}
+	}
+	contentType, ok := imageFormats[format]
+	if !ok {
+		return clientError(http.StatusBadRequest)
+	}
+
+	details, err := getImageDetails(ctx, &image)
+	if err != nil {
+		log.Printf("Failed to get details of image '%s': %s", key, err)
+		return serverError(err)
+	}
+
+	// Images that predate their details have no recorded checksum, so it's
+	// calculated from the original instead.
+	var original []byte
+	checksum := details.Sha256
+	if len(checksum) == 0 {
+		original, found, err = getObject(ctx, key)
+		if err != nil {
+			log.Printf("Failed to get image '%s': %s", key, err)
+			return serverError(err)
+		}
+		if !found {
+			return clientError(http.StatusNotFound)
+		}
+		sum := sha256.Sum256(original)
+		checksum = hex.EncodeToString(sum[:])
+	}
+
+	sizeLabel := "original"
+	if width > 0 {
+		sizeLabel = fmt.Sprintf("w%d", width)
+	}
+	derivedKey := fmt.Sprintf(
+		"%s%s/%s.%s",
+		forAnimal(derivedKeyPrefix, animal),
+		checksum,
+		sizeLabel,
+		format,
+	)
+
+	resized, found, err := getObject(ctx, derivedKey)
+	if err != nil {
+		// The cached variant is only an optimisation, so it's regenerated
+		// rather than failing the request.
+		log.Printf("Failed to get derived image '%s': %s", derivedKey, err)
+	}
+	if !found {
+		if original == nil {
+			original, found, err = getObject(ctx, key)
+			if err != nil {
+				log.Printf("Failed to get image '%s': %s", key, err)
+				return serverError(err)
+			}
+			if !found {
+				return clientError(http.StatusNotFound)
+			}
+		}
+
+		resized, err = resizeImage(original, width, format)
+		if err != nil {
+			log.Printf("Failed to resize image '%s': %s", key, err)
+			return serverError(err)
+		}
+
+		_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:       aws.String(bucketName),
+			Key:          aws.String(derivedKey),
+			Body:         bytes.NewReader(resized),
+			ContentType:  aws.String(contentType),
+			CacheControl: aws.String(resizeCacheControl),
+		})
+		if err != nil {
+			log.Printf("Failed to put derived image '%s': %s", derivedKey, err)
+		}
+	}
+	log.Printf("Successfully fetched image '%s' (%d bytes)", derivedKey, len(resized))
+
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":  contentType,
+			"Cache-Control": resizeCacheControl,
+		},
+		Body:            base64.StdEncoding.EncodeToString(resized),
+		IsBase64Encoded: true,
+	}, nil
+}
+
+// getHeader looks up a request header case-insensitively, as API Gateway passes
+// headers through with whatever case the client used.
+func getHeader(headers map[string]string, name string) (string, bool) {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// loadUploadsManifest reads the images uploaded for an animal. There's no
+// manifest until the first image has been uploaded.
+func loadUploadsManifest(ctx context.Context, animal string) ([]ImageManifestEntry, error) {
+	uploads, err := loadManifest(ctx, forAnimal(uploadsManifestKey, animal))
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return []ImageManifestEntry{}, nil
+		}
+		return nil, err
+	}
+	return uploads, nil
+}
+
+// parseImageUpload decodes the body of an upload request, which is either a
+// multipart form with an `image` file, or a JSON ImageUpload.
+func parseImageUpload(req events.APIGatewayProxyRequest) (*ImageUpload, []byte, error) {
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		var err error
+		body, err = base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	contentType, _ := getHeader(req.Headers, "Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" {
+		upload := new(ImageUpload)
+		err = json.Unmarshal(body, upload)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := base64.StdEncoding.DecodeString(upload.Image)
+		if err != nil {
+			return nil, nil, err
+		}
+		return upload, data, nil
+	}
+
+	upload := new(ImageUpload)
+	var data []byte
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Read one byte more than is allowed, so that oversized images can be
+		// told apart from images that are exactly the maximum size.
+		value, err := io.ReadAll(io.LimitReader(part, int64(uploadSizeMax)+1))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch part.FormName() {
+		case "image":
+			data = value
+			if len(upload.Name) == 0 {
+				upload.Name = part.FileName()
+			}
+		case "name":
+			upload.Name = string(value)
+		case "source":
+			upload.Source = string(value)
+		case "attribution":
+			upload.Attribution = string(value)
+		case "description":
+			upload.Description = string(value)
+		}
+	}
+
+	return upload, data, nil
+}
+
+// validateImageUpload checks an uploaded image and its details, returning the
+// image's content type, tags and details. The content type is sniffed from the
+// image itself, rather than trusting the client.
+func validateImageUpload(upload *ImageUpload, data []byte) (string, ImageTags, *ImageDetails, error) {
+	if !uploadNamePattern.MatchString(upload.Name) {
+		return "", nil, nil, fmt.Errorf("invalid name '%s'", upload.Name)
+	}
+
+	if len(data) == 0 {
+		return "", nil, nil, errors.New("no image supplied")
+	}
+	if len(data) > uploadSizeMax {
+		return "", nil, nil, fmt.Errorf("image is larger than %d bytes", uploadSizeMax)
+	}
+
+	contentType := http.DetectContentType(data)
+	extensions, ok := uploadContentTypes[contentType]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("unsupported content type '%s'", contentType)
+	}
+	extension := strings.ToLower(path.Ext(upload.Name))
+	matched := false
+	for _, allowed := range extensions {
+		if extension == allowed {
+			matched = true
+		}
+	}
+	if !matched {
+		return "", nil, nil, fmt.Errorf("name '%s' doesn't match content type '%s'", upload.Name, contentType)
+	}
+
+	imageConfig, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if imageConfig.Width > uploadDimensionMax || imageConfig.Height > uploadDimensionMax {
+		return "", nil, nil, fmt.Errorf(
+			"image is %dx%d, larger than %dx%d",
+			imageConfig.Width,
+			imageConfig.Height,
+			uploadDimensionMax,
+			uploadDimensionMax,
+		)
+	}
+
+	sourceUrl, err := url.Parse(upload.Source)
+	if err != nil || (sourceUrl.Scheme != "http" && sourceUrl.Scheme != "https") || len(sourceUrl.Host) == 0 {
+		return "", nil, nil, fmt.Errorf("source must be an http(s) URL, got '%s'", upload.Source)
+	}
+	if len(upload.Attribution) == 0 {
+		return "", nil, nil, errors.New("attribution is required")
+	}
+
+	tags := ImageTags{
+		"source":      upload.Source,
+		"attribution": upload.Attribution,
+	}
+	if len(upload.Description) > 0 {
+		tags["description"] = upload.Description
+	}
+	for name, value := range tags {
+		if utf8.RuneCountInString(value) > uploadTagLengthMax {
+			return "", nil, nil, fmt.Errorf("%s must be at most %d characters long", name, uploadTagLengthMax)
+		}
+		if !uploadTagPattern.MatchString(value) {
+			return "", nil, nil, fmt.Errorf("%s contains characters that can't be stored in an S3 tag", name)
+		}
+	}
+
+	checksum := sha256.Sum256(data)
+	details := &ImageDetails{
+		Width:  imageConfig.Width,
+		Height: imageConfig.Height,
+		Format: format,
+		Size:   int64(len(data)),
+		Sha256: hex.EncodeToString(checksum[:]),
+	}
+
+	return contentType, tags, details, nil
+}
+
+// isPreconditionFailed returns true if a conditional S3 write was rejected
+// because an object already exists at the key, or because a conflicting write
+// was in progress.
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict"
+}
+
+// uploadImage writes an uploaded image, along with its tags, to the animal's
+// image prefix and records it in the uploads manifest. Images can't be
+// overwritten, so the returned bool is false if the name is already taken.
+func uploadImage(ctx context.Context, animal string, name string, contentType string, tags ImageTags, details *ImageDetails, data []byte) (*ImageManifestEntry, bool, error) {
+	entry := &ImageManifestEntry{
+		Name:          name,
+		Key:           forAnimal(objectKeyPrefix, animal) + name,
+		Tags:          tags,
+		ImageDetails:  *details,
+		detailsLoaded: true,
+	}
+
+	_, found, err := findImage(ctx, animal, name)
+	if err != nil {
+		return nil, false, err
+	}
+	if found {
+		return nil, false, nil
+	}
+
+	// The index may be stale, and another upload of the same name may be in
+	// flight, so S3 is also asked to only write the image if no object exists
+	// at its key yet.
+	tagging := url.Values{}
+	for tagName, value := range tags {
+		tagging.Set(tagName, value)
+	}
+
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(entry.Key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+		Tagging:     aws.String(tagging.Encode()),
+		Metadata: map[string]string{
+			"width":  strconv.Itoa(details.Width),
+			"height": strconv.Itoa(details.Height),
+			"format": details.Format,
+			"size":   strconv.FormatInt(details.Size, 10),
+			"sha256": details.Sha256,
+		},
+	}, s3.WithAPIOptions(smithyhttp.AddHeaderValue("If-None-Match", "*")))
+	if isPreconditionFailed(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The uploads manifest is only needed when the index is read from the
+	// deploy-time manifest, as listing the bucket finds uploads anyway. Two
+	// uploads at the same moment may race here, in which case one of them is
+	// only found once the bucket is listed.
+	if len(uploadsManifestKey) > 0 {
+		uploads, err := loadUploadsManifest(ctx, animal)
+		if err != nil {
+			return nil, false, err
+		}
+
+		manifestJson, err := json.Marshal(ImageManifest{
+			Images: append(uploads, *entry),
+		})
+		if err != nil {
+			return nil, false, err
+		}
+
+		_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(bucketName),
+			Key:         aws.String(forAnimal(uploadsManifestKey, animal)),
+			Body:        bytes.NewReader(manifestJson),
+			ContentType: aws.String("application/json"),
+		})
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Forget the cached index, so the new image can be found straight away.
+	imageIndexesLock.Lock()
+	delete(imageIndexes, animal)
+	imageIndexesLock.Unlock()
+
+	return entry, true, nil
+}
+
+func processPost(ctx context.Context, animal string, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	upload, data, err := parseImageUpload(req)
+	if err != nil {
+		log.Printf("Failed to parse image upload: %s", err)
+		return clientError(http.StatusBadRequest)
+	}
+
+	contentType, tags, details, err := validateImageUpload(upload, data)
+	if err != nil {
+		log.Printf("Invalid image upload: %s", err)
+		if len(data) > uploadSizeMax {
+			return clientError(http.StatusRequestEntityTooLarge)
+		}
+		return clientError(http.StatusBadRequest)
+	}
+
+	entry, created, err := uploadImage(ctx, animal, upload.Name, contentType, tags, details, data)
+	if err != nil {
+		log.Printf("Failed to upload image: %s", err)
+		return serverError(err)
+	}
+	if !created {
+		return clientError(http.StatusConflict)
+	}
+
+	image, err := toImage(ctx, entry)
+	if err != nil {
+		log.Printf("Failed to get image: %s", err)
+		return serverError(err)
+	}
+
+	json, err := json.Marshal(image)
+	if err != nil {
+		log.Printf("Failed to json.Marshal(image): %s", err)
+		return serverError(err)
+	}
+	log.Printf("Successfully uploaded image: %s", json)
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusCreated,
 		Body:       string(json),
 	}, nil
 }
 
 func router(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Every route is scoped to an animal, e.g. `/otter/images`.
+	animal := req.PathParameters["animal"]
+	if !isKnownAnimal(animal) {
+		return clientError(http.StatusNotFound)
+	}
+
 	switch req.HTTPMethod {
 	case "GET":
-		return processGet(ctx)
+		if _, ok := req.PathParameters["name"]; ok {
+			return processGetResized(ctx, animal, req)
+		}
+		// List the images if asked to, otherwise return a random image.
+		// Either way, only images with the filtered tags are returned.
+		filters := parseTagFilters(req.QueryStringParameters)
+		if req.QueryStringParameters["list"] == "true" {
+			return processList(ctx, animal, req, filters)
+		}
+		ifNoneMatch, _ := getHeader(req.Headers, "If-None-Match")
+		return processGet(ctx, animal, filters, wantsRedirect(req), ifNoneMatch)
+	case "POST":
+		// The PAT has already been checked by the authorizer Lambda, which
+		// passes on its scopes.
+		scopes, ok := pattoken.ContextScopes(req.RequestContext.Authorizer)
+		if !ok {
+			return clientError(http.StatusUnauthorized)
+		}
+		if !pattoken.HasScope(scopes, pattoken.ScopeImagesWrite) {
+			return missingScope(pattoken.ScopeImagesWrite)
+		}
+		return processPost(ctx, animal, req)
 	default:
 		return clientError(http.StatusMethodNotAllowed)
 	}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// encodeTestImage returns a PNG of the given size.
+func encodeTestImage(t *testing.T, width int, height int) []byte {
+	t.Helper()
+	src := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, src); err != nil {
+		t.Fatalf("png.Encode() returned an error: %s", err)
+	}
+	return encoded.Bytes()
+}
+
+func TestCursor(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"simple", "animals/otter/images/otters01.png"},
+		{"unicode", "animals/otter/images/loutre-été.png"},
+		{"query characters", "animals/otter/images/a+b&c=d?.png"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor := encodeCursor(test.key)
+			if url.QueryEscape(cursor) != cursor {
+				t.Errorf("encodeCursor(%q) = %q, which isn't URL-safe", test.key, cursor)
+			}
+			got, err := decodeCursor(cursor)
+			if err != nil {
+				t.Fatalf("decodeCursor(%q) returned an error: %s", cursor, err)
+			}
+			if got != test.key {
+				t.Errorf("decodeCursor(encodeCursor(%q)) = %q", test.key, got)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, cursor := range []string{"not a cursor!", "a+b/c==", "x"} {
+		t.Run(cursor, func(t *testing.T) {
+			got, err := decodeCursor(cursor)
+			if err == nil {
+				t.Errorf("decodeCursor(%q) = %q, want an error", cursor, got)
+			}
+		})
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	tests := []struct {
+		name       string
+		width      int
+		height     int
+		resizeTo   int
+		format     string
+		wantWidth  int
+		wantHeight int
+	}{
+		{"smaller", 200, 100, 50, "png", 50, 25},
+		{"smaller jpeg", 200, 100, 50, "jpeg", 50, 25},
+		{"portrait", 100, 300, 30, "png", 30, 90},
+		{"rounded height", 300, 100, 100, "png", 100, 33},
+		{"never enlarged", 200, 100, 400, "png", 200, 100},
+		{"original size", 200, 100, 0, "jpeg", 200, 100},
+		{"at least a pixel high", 400, 1, 10, "png", 10, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resized, err := resizeImage(encodeTestImage(t, test.width, test.height), test.resizeTo, test.format)
+			if err != nil {
+				t.Fatalf("resizeImage() returned an error: %s", err)
+			}
+
+			config, format, err := image.DecodeConfig(bytes.NewReader(resized))
+			if err != nil {
+				t.Fatalf("image.DecodeConfig() returned an error: %s", err)
+			}
+			if format != test.format {
+				t.Errorf("resizeImage() returned a %s, want a %s", format, test.format)
+			}
+			if config.Width != test.wantWidth || config.Height != test.wantHeight {
+				t.Errorf(
+					"resizeImage(%dx%d, %d) = %dx%d, want %dx%d",
+					test.width,
+					test.height,
+					test.resizeTo,
+					config.Width,
+					config.Height,
+					test.wantWidth,
+					test.wantHeight,
+				)
+			}
+		})
+	}
+}
+
+func TestResizeImageInvalid(t *testing.T) {
+	_, err := resizeImage([]byte("not an image"), 100, "png")
+	if err == nil {
+		t.Errorf("resizeImage() returned no error")
+	}
+}
+
+func TestParseTagFilters(t *testing.T) {
+	tests := []struct {
+		name  string
+		query map[string]string
+		want  ImageTags
+	}{
+		{"none", map[string]string{}, ImageTags{}},
+		{"unrelated parameters", map[string]string{"limit": "10", "redirect": "true"}, ImageTags{}},
+		{"prefixed", map[string]string{"tag.attribution": "Adobe Stock"}, ImageTags{"attribution": "Adobe Stock"}},
+		{"custom tag", map[string]string{"tag.colour": "brown"}, ImageTags{"colour": "brown"}},
+		{"shorthand", map[string]string{"source": "https://example.com"}, ImageTags{"source": "https://example.com"}},
+		{"several", map[string]string{"tag.colour": "brown", "description": "river"}, ImageTags{"colour": "brown", "description": "river"}},
+		{"empty value", map[string]string{"tag.colour": ""}, ImageTags{"colour": ""}},
+		// The shorthand takes precedence over the prefixed parameter.
+		{"both forms", map[string]string{"tag.source": "a", "source": "b"}, ImageTags{"source": "b"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseTagFilters(test.query)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseTagFilters(%v) = %v, want %v", test.query, got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateImageUpload(t *testing.T) {
+	valid := encodeTestImage(t, 20, 10)
+	upload := func(name string) *ImageUpload {
+		return &ImageUpload{
+			Name:        name,
+			Source:      "https://example.com/otters",
+			Attribution: "Example",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		upload  *ImageUpload
+		data    []byte
+		wantErr bool
+	}{
+		{"valid", upload("otter.png"), valid, false},
+		{"upper case extension", upload("otter.PNG"), valid, false},
+		{"extension mismatch", upload("otter.jpg"), valid, true},
+		{"no extension", upload("otter"), valid, true},
+		{"wrong magic bytes", upload("otter.png"), append([]byte("GIF89a"), valid[6:]...), true},
+		{"not an image", upload("otter.png"), []byte("<html>otter</html>"), true},
+		{"truncated", upload("otter.png"), valid[:32], true},
+		{"empty", upload("otter.png"), []byte{}, true},
+		{"oversize", upload("otter.png"), append(append([]byte{}, valid...), make([]byte, uploadSizeMax)...), true},
+		{"too wide", upload("otter.png"), encodeTestImage(t, uploadDimensionMax+1, 1), true},
+		{"invalid name", upload("../otter.png"), valid, true},
+		{"no source", &ImageUpload{Name: "otter.png", Attribution: "Example"}, valid, true},
+		{"non-http source", &ImageUpload{Name: "otter.png", Source: "ftp://example.com", Attribution: "Example"}, valid, true},
+		{"no attribution", &ImageUpload{Name: "otter.png", Source: "https://example.com"}, valid, true},
+		{"long description", &ImageUpload{Name: "otter.png", Source: "https://example.com", Attribution: "Example", Description: strings.Repeat("a", uploadTagLengthMax+1)}, valid, true},
+		{"unsupported tag characters", &ImageUpload{Name: "otter.png", Source: "https://example.com", Attribution: "Example <3"}, valid, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contentType, tags, details, err := validateImageUpload(test.upload, test.data)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("validateImageUpload(%s) returned no error", test.upload.Name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateImageUpload(%s) returned an error: %s", test.upload.Name, err)
+			}
+			if contentType != "image/png" {
+				t.Errorf("validateImageUpload(%s) content type = %s, want image/png", test.upload.Name, contentType)
+			}
+			if tags["source"] != test.upload.Source || tags["attribution"] != test.upload.Attribution {
+				t.Errorf("validateImageUpload(%s) tags = %v", test.upload.Name, tags)
+			}
+			if details.Width != 20 || details.Height != 10 || details.Size != int64(len(test.data)) {
+				t.Errorf("validateImageUpload(%s) details = %+v", test.upload.Name, details)
+			}
+		})
+	}
+}
+
+func TestWantsRedirect(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  map[string]string
+		accept string
+		want   bool
+	}{
+		{"no accept header", nil, "", false},
+		{"redirect parameter", map[string]string{"redirect": "true"}, "application/json", true},
+		{"redirect parameter off", map[string]string{"redirect": "false"}, "", false},
+		{"json", nil, "application/json", false},
+		{"anything", nil, "*/*", false},
+		{"chrome navigation", nil, "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7", false},
+		{"firefox navigation", nil, "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8", false},
+		{"chrome img", nil, "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8", true},
+		{"firefox img", nil, "image/avif,image/webp,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5", true},
+		{"safari img", nil, "image/webp,image/avif,image/jxl,image/heic,image/heic-sequence,video/*;q=0.8,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5", true},
+		{"images only", nil, "image/*", true},
+		{"json preferred", nil, "image/*;q=0.5,application/json", false},
+		{"image preferred", nil, "image/png,application/json;q=0.5", true},
+		{"images refused", nil, "image/*;q=0", false},
+		{"case insensitive", nil, "Image/PNG", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{
+				QueryStringParameters: test.query,
+			}
+			if len(test.accept) > 0 {
+				req.Headers = map[string]string{"accept": test.accept}
+			}
+			got := wantsRedirect(req)
+			if got != test.want {
+				t.Errorf("wantsRedirect(%q) = %t, want %t", test.accept, got, test.want)
+			}
+		})
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	etag := getETag([]byte(`{"key":"otters01.png"}`))
+	if etag != getETag([]byte(`{"key":"otters01.png"}`)) {
+		t.Errorf("getETag() isn't stable")
+	}
+	if etag == getETag([]byte(`{"key":"otters02.png"}`)) {
+		t.Errorf("getETag() is the same for different bodies")
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{"same", etag, true},
+		{"weak", "W/" + etag, true},
+		{"listed", `"other", ` + etag, true},
+		{"any", "*", true},
+		{"different", `"other"`, false},
+		{"unquoted", strings.Trim(etag, `"`), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := etagMatches(test.ifNoneMatch, etag)
+			if got != test.want {
+				t.Errorf("etagMatches(%q, %q) = %t, want %t", test.ifNoneMatch, etag, got, test.want)
+			}
+		})
+	}
+}
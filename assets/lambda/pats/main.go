@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -17,22 +22,72 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"cdn"
+	"pattoken"
 )
 
 const acronymEnvVar = string("ACRONYM")
 const acronymDefault = string("xaas")
-const patFormat = string("%s_pat_%s")
-const patSuffixLength = int(64)
 const tableNameEnvVar = string("PAT_TABLE_NAME")
 const tableNameDefault = string("xaas-api-pats")
 
+// PATs expire after the number of days given by the `expiresInDays` query
+// parameter, which is capped at patLifetimeDaysMax.
+const patLifetimeDaysMaxEnvVar = string("PAT_LIFETIME_DAYS_MAX")
+const patLifetimeDaysMaxDefault = int(90)
+const patLifetimeDaysDefault = int(30)
+
+// PATs can be listed a page at a time, by holders of a `pats:admin` PAT.
+const listLimitDefault = int(25)
+const listLimitMax = int(100)
+
 var ddbClient dynamodb.Client
 var acronym string
 var tableName string
-var sequenceLetters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+var patLifetimeDaysMax int
+
+// Behind the stack's CloudFront distribution, links to further pages are on
+// the distribution. Its domain name is read from the stack's parameter.
+var cdnDomain *cdn.Domain
 
+// Pat is returned to the client when a PAT is created. It's the only time the
+// plaintext PAT is ever seen.
 type Pat struct {
-	Pat string `dynamodbav:"Pat" json:"pat"`
+	Pat       string    `json:"pat"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PatItem is how a PAT is stored in the table. Only the SHA-256 digest of the
+// PAT is stored, so the table can't be used to impersonate anyone.
+// It's deleted by DynamoDB's TTL once it expires.
+type PatItem struct {
+	Pat       string   `dynamodbav:"Pat"`
+	Scopes    []string `dynamodbav:"Scopes,omitempty"`
+	ExpiresAt int64    `dynamodbav:"ExpiresAt,omitempty"`
+	// When the PAT was created, and when it was last used, in seconds since
+	// the epoch. PATs created before these were recorded don't have them, and
+	// the authorizer Lambda only records when a PAT was last used every so
+	// often.
+	CreatedAt  int64 `dynamodbav:"CreatedAt,omitempty"`
+	LastUsedAt int64 `dynamodbav:"LastUsedAt,omitempty"`
+}
+
+// PatMetadata describes a PAT, without giving away the PAT itself. Its ID is
+// the digest that it's stored under.
+type PatMetadata struct {
+	Id         string     `json:"id"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+type PatPage struct {
+	Pats   []PatMetadata `json:"pats"`
+	Cursor string        `json:"cursor,omitempty"`
+	Next   string        `json:"next,omitempty"`
 }
 
 func init() {
@@ -42,6 +97,7 @@ func init() {
 	}
 
 	ddbClient = *dynamodb.NewFromConfig(sdkConfig)
+	cdnDomain = cdn.New(sdkConfig)
 
 	// Grab the acronym from the environment variables.
 	// If the environment variable is not defined, fall back to a default.
@@ -56,6 +112,15 @@ func init() {
 	if len(tableName) == 0 {
 		tableName = tableNameDefault
 	}
+
+	patLifetimeDaysMax = patLifetimeDaysMaxDefault
+	lifetimeStr := os.Getenv(patLifetimeDaysMaxEnvVar)
+	if len(lifetimeStr) > 0 {
+		patLifetimeDaysMax, err = strconv.Atoi(lifetimeStr)
+		if err != nil || patLifetimeDaysMax <= 0 {
+			log.Fatalf("Invalid %s '%s'", patLifetimeDaysMaxEnvVar, lifetimeStr)
+		}
+	}
 }
 
 func clientError(status int) (events.APIGatewayProxyResponse, error) {
@@ -65,6 +130,37 @@ func clientError(status int) (events.APIGatewayProxyResponse, error) {
 	}, nil
 }
 
+// invalidScopes is returned when the requested scopes can't be parsed, saying
+// what was wrong with them.
+func invalidScopes(err error) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{
+		Body:       fmt.Sprintf("%s: %s", http.StatusText(http.StatusBadRequest), err),
+		StatusCode: http.StatusBadRequest,
+	}, nil
+}
+
+// privilegedScopeRefused is returned when a PAT is asked for with a scope that
+// the caller isn't allowed to grant.
+func privilegedScopeRefused(scope string) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{
+		Body: fmt.Sprintf(
+			"%s: the '%s' scope can only be granted through /pats/privileged, by a PAT that has it",
+			http.StatusText(http.StatusForbidden),
+			scope,
+		),
+		StatusCode: http.StatusForbidden,
+	}, nil
+}
+
+// missingScope is returned when a valid PAT hasn't been granted the scope that
+// the route needs.
+func missingScope(scope string) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{
+		Body:       fmt.Sprintf("%s: the PAT doesn't have the '%s' scope", http.StatusText(http.StatusForbidden), scope),
+		StatusCode: http.StatusForbidden,
+	}, nil
+}
+
 func serverError(err error) (events.APIGatewayProxyResponse, error) {
 	return events.APIGatewayProxyResponse{
 		Body:       http.StatusText(http.StatusInternalServerError),
@@ -72,10 +168,19 @@ func serverError(err error) (events.APIGatewayProxyResponse, error) {
 	}, nil
 }
 
+// getHeader looks up a request header case-insensitively, as API Gateway passes
+// headers through with whatever case the client used.
+func getHeader(headers map[string]string, name string) (string, bool) {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
 func isDuplicatePat(ctx context.Context, pat string) (bool, error) {
-	// Grab the name of the pat table from the environment variables.
-	// If the environment variable is not defined, fall back to a default.
-	tableKey, err := attributevalue.Marshal(pat)
+	tableKey, err := attributevalue.Marshal(pattoken.Hash(pat))
 	if err != nil {
 		return false, err
 	}
@@ -93,28 +198,23 @@ func isDuplicatePat(ctx context.Context, pat string) (bool, error) {
 	}
 
 	if result.Item != nil {
-		log.Printf("PAT '%s' is a duplicate", pat)
+		log.Printf("PAT '%s' is a duplicate", pattoken.Redact(pat))
 		return true, nil
 	} else {
-		log.Printf("PAT '%s' is NOT a duplicate", pat)
+		log.Printf("PAT '%s' is NOT a duplicate", pattoken.Redact(pat))
 		return false, nil
 	}
 }
 
-func generatePat() string {
-	patSuffix := make([]rune, patSuffixLength)
-	for i := range patSuffix {
-		patSuffix[i] = sequenceLetters[rand.Intn(len(sequenceLetters))]
-	}
-
-	return fmt.Sprintf(patFormat, acronym, string(patSuffix))
-}
-
 func generateUniquePat(ctx context.Context) (string, error) {
 	pat := string("")
 
 	for {
-		pat = generatePat()
+		var err error
+		pat, err = pattoken.Generate(acronym)
+		if err != nil {
+			return string(""), err
+		}
 		isDuplicate, err := isDuplicatePat(ctx, pat)
 		if err != nil {
 			return string(""), err
@@ -127,17 +227,22 @@ func generateUniquePat(ctx context.Context) (string, error) {
 	return pat, nil
 }
 
-func postNewPat(ctx context.Context) (*Pat, error) {
+func postNewPat(ctx context.Context, scopes []string, lifetimeDays int) (*Pat, error) {
 	pat, err := generateUniquePat(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	patStruct := Pat{
-		Pat: pat,
-	}
+	// DynamoDB's TTL works in whole seconds.
+	createdAt := time.Now().Truncate(time.Second)
+	expiresAt := createdAt.AddDate(0, 0, lifetimeDays)
 
-	item, err := attributevalue.MarshalMap(patStruct)
+	item, err := attributevalue.MarshalMap(PatItem{
+		Pat:       pattoken.Hash(pat),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt.Unix(),
+		CreatedAt: createdAt.Unix(),
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -152,34 +257,409 @@ func postNewPat(ctx context.Context) (*Pat, error) {
 		return nil, err
 	}
 
-	return &patStruct, nil
+	return &Pat{
+		Pat:       pat,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt.UTC(),
+	}, nil
 }
 
-func deletePat(ctx context.Context, pat string) error {
-	patStruct := Pat{
-		Pat: pat,
+// deletePat deletes a PAT, whether it's stored as a digest or, if it hasn't
+// been migrated yet, in plaintext. It returns the deleted item, or nil if the
+// PAT didn't exist.
+func deletePat(ctx context.Context, pat string) (*PatItem, error) {
+	for _, key := range []string{pattoken.Hash(pat), pat} {
+		tableKey, err := attributevalue.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+
+		// The condition tells us whether there was anything to delete.
+		result, err := ddbClient.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(tableName),
+			Key: map[string]types.AttributeValue{
+				"Pat": tableKey,
+			},
+			ConditionExpression: aws.String("attribute_exists(Pat)"),
+			ReturnValues:        types.ReturnValueAllOld,
+		})
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		item := PatItem{}
+		err = attributevalue.UnmarshalMap(result.Attributes, &item)
+		if err != nil {
+			return nil, err
+		}
+
+		// A PAT that hadn't been migrated is still identified by its digest,
+		// so that it isn't echoed back.
+		item.Pat = pattoken.Hash(pat)
+		return &item, nil
 	}
 
-	item, err := attributevalue.MarshalMap(patStruct)
+	return nil, nil
+}
+
+// parseLifetimeDays reads how many days a new PAT should last for, capping it
+// at patLifetimeDaysMax.
+func parseLifetimeDays(req events.APIGatewayProxyRequest) (int, error) {
+	lifetimeDays := patLifetimeDaysDefault
+	lifetimeStr, ok := req.QueryStringParameters["expiresInDays"]
+	if ok {
+		var err error
+		lifetimeDays, err = strconv.Atoi(lifetimeStr)
+		if err != nil {
+			return 0, err
+		}
+		if lifetimeDays <= 0 {
+			return 0, fmt.Errorf("expiresInDays must be positive, got: %d", lifetimeDays)
+		}
+	}
+
+	if lifetimeDays > patLifetimeDaysMax {
+		lifetimeDays = patLifetimeDaysMax
+	}
+	return lifetimeDays, nil
+}
+
+// parseScopes reads the scopes that a new PAT should be granted, from the
+// comma-separated `scopes` query parameter.
+func parseScopes(req events.APIGatewayProxyRequest) ([]string, error) {
+	scopeList, ok := req.QueryStringParameters["scopes"]
+	if !ok {
+		return pattoken.DefaultScopes, nil
+	}
+
+	scopes, err := pattoken.ParseScopes(scopeList)
+	if err != nil {
+		return nil, err
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope must be requested")
+	}
+
+	return scopes, nil
+}
+
+// privilegedScope returns the first of the requested scopes that the caller
+// isn't allowed to grant. Anyone can create a PAT with the default scopes, none
+// of which allow anything to be written, but any other scope can only be
+// granted by a PAT that already has it, which the authorizer Lambda passes on
+// in the request context. The first such PAT has to be created out-of-band.
+func privilegedScope(req events.APIGatewayProxyRequest, scopes []string) (string, bool) {
+	granted, authorised := pattoken.ContextScopes(req.RequestContext.Authorizer)
+	for _, scope := range scopes {
+		if pattoken.HasScope(pattoken.DefaultScopes, scope) {
+			continue
+		}
+		if !authorised || !pattoken.HasScope(granted, scope) {
+			return scope, true
+		}
+	}
+	return "", false
+}
+
+// unixTime converts a time stored in seconds since the epoch, returning nil if
+// it wasn't stored.
+func unixTime(seconds int64) *time.Time {
+	if seconds == 0 {
+		return nil
+	}
+	t := time.Unix(seconds, 0).UTC()
+	return &t
+}
+
+// toPatMetadata describes a PAT. PATs that are still stored in plaintext are
+// identified by their digest, as they will be once they're migrated, so that
+// they aren't given away.
+func toPatMetadata(item PatItem) PatMetadata {
+	scopes := item.Scopes
+	if scopes == nil {
+		scopes = pattoken.LegacyScopes
+	}
+	patId := item.Pat
+	if pattoken.Validate(patId) == nil {
+		patId = pattoken.Hash(patId)
+	}
+	return PatMetadata{
+		Id:         patId,
+		Scopes:     scopes,
+		CreatedAt:  unixTime(item.CreatedAt),
+		ExpiresAt:  unixTime(item.ExpiresAt),
+		LastUsedAt: unixTime(item.LastUsedAt),
+	}
+}
+
+// getPatItem gets the item that a PAT is stored as, by its ID.
+func getPatItem(ctx context.Context, patId string) (*PatItem, error) {
+	tableKey, err := attributevalue.Marshal(patId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	input := &dynamodb.DeleteItemInput{
+	result, err := ddbClient.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(tableName),
-		Key:       item,
+		Key: map[string]types.AttributeValue{
+			"Pat": tableKey,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
 	}
 
-	_, err = ddbClient.DeleteItem(ctx, input)
+	item := PatItem{}
+	err = attributevalue.UnmarshalMap(result.Item, &item)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return &item, nil
+}
+
+// encodeCursor turns the LastEvaluatedKey of a Scan into an opaque, URL-safe
+// token that can be handed back to fetch the next page.
+func encodeCursor(lastEvaluatedKey map[string]types.AttributeValue) (string, error) {
+	var cursor PatItem
+	err := attributevalue.UnmarshalMap(lastEvaluatedKey, &cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor.Pat)), nil
+}
 
-	return nil
+// isPlaintextKey reports whether a key is a PAT that's still stored in
+// plaintext, rather than a digest.
+func isPlaintextKey(key map[string]types.AttributeValue) (bool, error) {
+	if len(key) == 0 {
+		return false, nil
+	}
+
+	var item PatItem
+	err := attributevalue.UnmarshalMap(key, &item)
+	if err != nil {
+		return false, err
+	}
+	return pattoken.Validate(item.Pat) == nil, nil
 }
 
-func processPost(ctx context.Context) (events.APIGatewayProxyResponse, error) {
-	pat, err := postNewPat(ctx)
+// decodeCursor reverses encodeCursor, returning a key that can be used as the
+// ExclusiveStartKey of a Scan.
+func decodeCursor(token string) (map[string]types.AttributeValue, error) {
+	patId, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return attributevalue.MarshalMap(PatItem{
+		Pat: string(patId),
+	})
+}
+
+// getCdnDomain returns the domain name of the stack's distribution, or an
+// empty string if it has none.
+func getCdnDomain(ctx context.Context) string {
+	domain, err := cdnDomain.Get(ctx)
+	if err != nil {
+		log.Printf("Failed to get the CDN domain: %s", err)
+	}
+	return domain
+}
+
+// nextLink builds the URL of the following page, preserving the limit.
+func nextLink(ctx context.Context, req events.APIGatewayProxyRequest, limit int, cursor string) string {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("cursor", cursor)
+
+	// The distribution adds the stage to the path itself.
+	domain := getCdnDomain(ctx)
+	if len(domain) > 0 {
+		return fmt.Sprintf("https://%s%s?%s", domain, req.Path, query.Encode())
+	}
+
+	path := req.Path
+	if len(req.RequestContext.Stage) > 0 {
+		path = fmt.Sprintf("/%s%s", req.RequestContext.Stage, path)
+	}
+
+	host, ok := getHeader(req.Headers, "Host")
+	if !ok {
+		return fmt.Sprintf("%s?%s", path, query.Encode())
+	}
+	return fmt.Sprintf("https://%s%s?%s", host, path, query.Encode())
+}
+
+// processGetSelf describes the PAT that the request was authorised with.
+func processGetSelf(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	patId, ok := pattoken.ContextPatId(req.RequestContext.Authorizer)
+	if !ok {
+		return clientError(http.StatusUnauthorized)
+	}
+
+	item, err := getPatItem(ctx, patId)
+	if err != nil {
+		log.Printf("Failed to get pat: %s", err)
+		return serverError(err)
+	}
+	if item == nil {
+		return clientError(http.StatusNotFound)
+	}
+
+	json, err := json.Marshal(toPatMetadata(*item))
+	if err != nil {
+		log.Printf("Failed to json.Marshal(pat): %s", err)
+		return serverError(err)
+	}
+	log.Printf("Successfully described pat: %s", pattoken.Redact(patId))
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(json),
+	}, nil
+}
+
+// processList lists a page of PATs. Only holders of a `pats:admin` PAT can
+// list them.
+func processList(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	scopes, ok := pattoken.ContextScopes(req.RequestContext.Authorizer)
+	if !ok {
+		return clientError(http.StatusUnauthorized)
+	}
+	if !pattoken.HasScope(scopes, pattoken.ScopePatsAdmin) {
+		return missingScope(pattoken.ScopePatsAdmin)
+	}
+
+	limit := listLimitDefault
+	limitStr, ok := req.QueryStringParameters["limit"]
+	if ok {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return clientError(http.StatusBadRequest)
+		}
+		if limit > listLimitMax {
+			limit = listLimitMax
+		}
+	}
+
+	var startKey map[string]types.AttributeValue
+	cursorStr, ok := req.QueryStringParameters["cursor"]
+	if ok && len(cursorStr) > 0 {
+		var err error
+		startKey, err = decodeCursor(cursorStr)
+		if err != nil {
+			log.Printf("Failed to decode cursor '%s': %s", cursorStr, err)
+			return clientError(http.StatusBadRequest)
+		}
+	}
+
+	scanResults, err := ddbClient.Scan(ctx, &dynamodb.ScanInput{
+		TableName:         aws.String(tableName),
+		Limit:             aws.Int32(int32(limit)),
+		ExclusiveStartKey: startKey,
+	})
+	if err != nil {
+		log.Printf("Failed to list pats: %s", err)
+		return serverError(err)
+	}
+
+	items := []PatItem{}
+	err = attributevalue.UnmarshalListOfMaps(scanResults.Items, &items)
+	if err != nil {
+		log.Printf("Failed to unmarshal pats: %s", err)
+		return serverError(err)
+	}
+
+	// The cursor is the key of the last PAT on the page, which would give
+	// away a PAT that's still stored in plaintext, so the page carries on
+	// past any at its end.
+	lastEvaluatedKey := scanResults.LastEvaluatedKey
+	for {
+		plaintext, err := isPlaintextKey(lastEvaluatedKey)
+		if err != nil {
+			log.Printf("Failed to unmarshal cursor: %s", err)
+			return serverError(err)
+		}
+		if !plaintext {
+			break
+		}
+
+		scanResults, err = ddbClient.Scan(ctx, &dynamodb.ScanInput{
+			TableName:         aws.String(tableName),
+			Limit:             aws.Int32(1),
+			ExclusiveStartKey: lastEvaluatedKey,
+		})
+		if err != nil {
+			log.Printf("Failed to list pats: %s", err)
+			return serverError(err)
+		}
+
+		more := []PatItem{}
+		err = attributevalue.UnmarshalListOfMaps(scanResults.Items, &more)
+		if err != nil {
+			log.Printf("Failed to unmarshal pats: %s", err)
+			return serverError(err)
+		}
+		items = append(items, more...)
+		lastEvaluatedKey = scanResults.LastEvaluatedKey
+	}
+
+	page := PatPage{
+		Pats: make([]PatMetadata, 0, len(items)),
+	}
+	for _, item := range items {
+		page.Pats = append(page.Pats, toPatMetadata(item))
+	}
+
+	if len(lastEvaluatedKey) > 0 {
+		page.Cursor, err = encodeCursor(lastEvaluatedKey)
+		if err != nil {
+			log.Printf("Failed to encode cursor: %s", err)
+			return serverError(err)
+		}
+		page.Next = nextLink(ctx, req, limit, page.Cursor)
+	}
+
+	json, err := json.Marshal(page)
+	if err != nil {
+		log.Printf("Failed to json.Marshal(page): %s", err)
+		return serverError(err)
+	}
+	log.Printf("Successfully listed %d pats", len(page.Pats))
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(json),
+	}, nil
+}
+
+func processPost(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	scopes, err := parseScopes(req)
+	if err != nil {
+		log.Printf("Invalid pat scopes: %s", err)
+		return invalidScopes(err)
+	}
+	if scope, ok := privilegedScope(req, scopes); ok {
+		log.Printf("Refused to create a pat with the '%s' scope", scope)
+		return privilegedScopeRefused(scope)
+	}
+
+	lifetimeDays, err := parseLifetimeDays(req)
+	if err != nil {
+		log.Printf("Invalid pat lifetime: %s", err)
+		return clientError(http.StatusBadRequest)
+	}
+
+	pat, err := postNewPat(ctx, scopes, lifetimeDays)
 	if err != nil {
 		log.Printf("Failed to create new pat: %s", err)
 		return serverError(err)
@@ -195,7 +675,7 @@ func processPost(ctx context.Context) (events.APIGatewayProxyResponse, error) {
 		log.Printf("Failed to json.Marshal(pat): %s", err)
 		return serverError(err)
 	}
-	log.Printf("Successfully create new pat: %s", json)
+	log.Printf("Successfully create new pat: %s", pattoken.Redact(pat.Pat))
 
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusOK,
@@ -203,19 +683,39 @@ func processPost(ctx context.Context) (events.APIGatewayProxyResponse, error) {
 	}, nil
 }
 
-func processDelete(ctx context.Context, pat string) (events.APIGatewayProxyResponse, error) {
-	err := deletePat(ctx, pat)
+// processDelete deletes the PAT supplied in the Authorization header. The route
+// isn't behind the authorizer Lambda, so that unknown PATs can be told apart
+// from invalid ones.
+func processDelete(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	header, ok := getHeader(req.Headers, "Authorization")
+	if !ok {
+		return clientError(http.StatusUnauthorized)
+	}
+
+	// Malformed and mistyped PATs can be rejected without looking them up.
+	pat := pattoken.ParseBearerToken(header)
+	if pattoken.Validate(pat) != nil {
+		return clientError(http.StatusUnauthorized)
+	}
+
+	item, err := deletePat(ctx, pat)
 	if err != nil {
 		log.Printf("Failed to delete pat: %s", err)
 		return serverError(err)
 	}
+	if item == nil {
+		log.Printf("PAT '%s' doesn't exist", pattoken.Redact(pat))
+		return clientError(http.StatusNotFound)
+	}
 
-	json, err := json.Marshal(pat)
+	// Only the deleted PAT's metadata is returned, so the PAT itself doesn't
+	// end up in any logs or caches along the way.
+	json, err := json.Marshal(toPatMetadata(*item))
 	if err != nil {
 		log.Printf("Failed to json.Marshal(pat): %s", err)
 		return serverError(err)
 	}
-	log.Printf("Successfully deleted pat: %s", json)
+	log.Printf("Successfully deleted pat: %s", pattoken.Redact(item.Pat))
 
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusOK,
@@ -225,14 +725,15 @@ func processDelete(ctx context.Context, pat string) (events.APIGatewayProxyRespo
 
 func router(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	switch req.HTTPMethod {
+	case "GET":
+		if req.Resource == "/pats/self" {
+			return processGetSelf(ctx, req)
+		}
+		return processList(ctx, req)
 	case "POST":
-		return processPost(ctx)
+		return processPost(ctx, req)
 	case "DELETE":
-		suppliedPat, ok := req.Headers["Authorization"]
-		if !ok {
-			return clientError(http.StatusUnauthorized)
-		}
-		return processDelete(ctx, suppliedPat)
+		return processDelete(ctx, req)
 	default:
 		return clientError(http.StatusMethodNotAllowed)
 	}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"pattoken"
+)
+
+// Each of these requests is refused before the table is touched, so they don't
+// need AWS.
+func TestProcessPostPrivilegedScopes(t *testing.T) {
+	tests := []struct {
+		name       string
+		scopes     string
+		authorizer map[string]interface{}
+	}{
+		{"unauthorised", pattoken.ScopePatsAdmin, nil},
+		{"unauthorised write", pattoken.ScopeFactsWrite, nil},
+		{"unauthorised with default scopes", "facts:read," + pattoken.ScopeImagesWrite, nil},
+		{"no pat in context", pattoken.ScopePatsAdmin, map[string]interface{}{
+			pattoken.ContextScopesKey: pattoken.ScopePatsAdmin,
+		}},
+		{"missing scope", pattoken.ScopePatsAdmin, map[string]interface{}{
+			pattoken.ContextPatIdKey:  "digest",
+			pattoken.ContextScopesKey: "facts:write,images:write",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{
+				HTTPMethod:            "POST",
+				QueryStringParameters: map[string]string{"scopes": test.scopes},
+				RequestContext: events.APIGatewayProxyRequestContext{
+					Authorizer: test.authorizer,
+				},
+			}
+			res, err := processPost(context.Background(), req)
+			if err != nil {
+				t.Fatalf("processPost() returned an error: %s", err)
+			}
+			if res.StatusCode != http.StatusForbidden {
+				t.Errorf("processPost() = %d, want %d", res.StatusCode, http.StatusForbidden)
+			}
+		})
+	}
+}
+
+func TestPrivilegedScope(t *testing.T) {
+	admin := map[string]interface{}{
+		pattoken.ContextPatIdKey:  "digest",
+		pattoken.ContextScopesKey: "facts:write,pats:admin",
+	}
+	legacy := map[string]interface{}{
+		pattoken.ContextPatIdKey:  "digest",
+		pattoken.ContextScopesKey: strings.Join(pattoken.LegacyScopes, ","),
+	}
+
+	tests := []struct {
+		name       string
+		scopes     []string
+		authorizer map[string]interface{}
+		want       bool
+	}{
+		{"default scopes", pattoken.DefaultScopes, nil, false},
+		{"unauthorised", []string{pattoken.ScopePatsAdmin}, nil, true},
+		{"authorised", []string{pattoken.ScopePatsAdmin}, admin, false},
+		{"unauthorised write", []string{pattoken.ScopeFactsWrite}, nil, true},
+		{"authorised with default scopes", []string{pattoken.ScopeFactsRead, pattoken.ScopePatsAdmin}, admin, false},
+		{"authorised without every scope", []string{pattoken.ScopeImagesWrite, pattoken.ScopePatsAdmin}, admin, true},
+		{"legacy write", pattoken.LegacyScopes, legacy, false},
+		{"legacy admin", []string{pattoken.ScopePatsAdmin}, legacy, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{
+				RequestContext: events.APIGatewayProxyRequestContext{
+					Authorizer: test.authorizer,
+				},
+			}
+			_, got := privilegedScope(req, test.scopes)
+			if got != test.want {
+				t.Errorf("privilegedScope(%v) = %t, want %t", test.scopes, got, test.want)
+			}
+		})
+	}
+}
+
+func TestToPatMetadata(t *testing.T) {
+	pat, err := pattoken.Generate("xaas")
+	if err != nil {
+		t.Fatalf("Generate() returned an error: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"digest", pattoken.Hash(pat)},
+		{"plaintext", pat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := toPatMetadata(PatItem{Pat: test.key})
+			if got.Id != pattoken.Hash(pat) {
+				t.Errorf("toPatMetadata(%s).Id = %s, want %s", pattoken.Redact(test.key), got.Id, pattoken.Hash(pat))
+			}
+		})
+	}
+}
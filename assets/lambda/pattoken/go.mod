@@ -0,0 +1,3 @@
+module pattoken
+
+go 1.20
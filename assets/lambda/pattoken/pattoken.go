@@ -0,0 +1,253 @@
+// Package pattoken generates, validates and hashes the PATs handed out by the
+// pats Lambda. A PAT looks like `<acronym>_pat_<random><checksum>`, where the
+// random part is drawn from crypto/rand, and the checksum is the CRC32 of the
+// random part. Both are base62-encoded, so a PAT can be double-clicked and
+// copied whole. The checksum lets clients, secret scanners and the other
+// Lambdas recognise a PAT, and reject a mistyped one, without looking it up.
+package pattoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The segment that separates the acronym from the rest of the PAT.
+const Separator = string("_pat_")
+
+// The random part has 30 base62 characters, which is just under 179 bits of
+// entropy. The checksum needs 6 base62 characters to hold a CRC32.
+const RandomLength = int(30)
+const ChecksumLength = int(6)
+
+// PATs created before they were checksummed have a 64 character random part,
+// and nothing else.
+const legacyRandomLength = int(64)
+
+const base62Alphabet = string("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz")
+
+// Random bytes at or above this are rejected, so that every character of the
+// alphabet is equally likely.
+const randomByteLimit = byte(256 - 256%len(base62Alphabet))
+
+// PATs are only ever logged as a short prefix, which is enough to tell them
+// apart without letting anyone who can read the logs use them.
+const RedactedLength = int(12)
+
+// The attribute that holds the time a PAT expires at, in seconds since the
+// epoch, as DynamoDB's TTL expects.
+const ExpiresAtAttribute = string("ExpiresAt")
+
+// The attribute that holds the scopes a PAT has been granted.
+const ScopesAttribute = string("Scopes")
+
+// The scopes that a PAT can be granted, each of which allows the PAT to be used
+// on some of the API's routes. Reading facts and images doesn't need a PAT, so
+// `facts:read` doesn't yet allow anything that can't be done without one.
+const ScopeFactsRead = string("facts:read")
+const ScopeFactsWrite = string("facts:write")
+const ScopeImagesWrite = string("images:write")
+const ScopePatsAdmin = string("pats:admin")
+
+var Scopes = []string{ScopeFactsRead, ScopeFactsWrite, ScopeImagesWrite, ScopePatsAdmin}
+
+// The scopes granted to a PAT when none are asked for. These are the only
+// scopes that can be granted without already holding them.
+var DefaultScopes = []string{ScopeFactsRead}
+
+// The scopes of PATs created before they were scoped, which could do anything
+// but manage other PATs.
+var LegacyScopes = []string{ScopeFactsWrite, ScopeImagesWrite}
+
+// The keys, in the request context passed on by the authorizer Lambda, that
+// hold the ID of the PAT that the request was authorised with, and its
+// comma-separated scopes.
+const ContextPatIdKey = string("patId")
+const ContextScopesKey = string("scopes")
+
+var ErrMalformed = errors.New("pat is malformed")
+var ErrChecksum = errors.New("pat checksum does not match")
+var ErrUnknownScope = errors.New("unknown scope")
+
+// Prefix returns the prefix of every PAT issued under the given acronym.
+func Prefix(acronym string) string {
+	return acronym + Separator
+}
+
+// Generate returns a new PAT for the given acronym.
+func Generate(acronym string) (string, error) {
+	random := make([]byte, 0, RandomLength)
+	buffer := make([]byte, RandomLength)
+	for len(random) < RandomLength {
+		_, err := rand.Read(buffer)
+		if err != nil {
+			return "", err
+		}
+		for _, b := range buffer {
+			if b >= randomByteLimit || len(random) == RandomLength {
+				continue
+			}
+			random = append(random, base62Alphabet[int(b)%len(base62Alphabet)])
+		}
+	}
+
+	return fmt.Sprintf("%s%s%s", Prefix(acronym), random, checksum(string(random))), nil
+}
+
+// ParseBearerToken extracts the PAT from an Authorization header value. The
+// scheme is matched case-insensitively, and both `Bearer <pat>` and
+// `Bearer: <pat>` are accepted, as is a bare PAT.
+func ParseBearerToken(header string) string {
+	token := strings.TrimSpace(header)
+	scheme, rest, found := strings.Cut(token, " ")
+	if found && strings.EqualFold(strings.TrimSuffix(scheme, ":"), "Bearer") {
+		token = strings.TrimSpace(rest)
+	}
+	return token
+}
+
+// Validate checks that a PAT is well-formed, and that its checksum matches.
+// It doesn't check that the PAT exists. PATs created before they were
+// checksummed are accepted as long as they're well-formed.
+func Validate(pat string) error {
+	index := strings.LastIndex(pat, Separator)
+	if index <= 0 {
+		return ErrMalformed
+	}
+	body := pat[index+len(Separator):]
+	if !isAcronym(pat[:index]) {
+		return ErrMalformed
+	}
+	if !isBase62(body) {
+		return ErrMalformed
+	}
+
+	switch len(body) {
+	case legacyRandomLength:
+		return nil
+	case RandomLength + ChecksumLength:
+		if body[RandomLength:] != checksum(body[:RandomLength]) {
+			return ErrChecksum
+		}
+		return nil
+	default:
+		return ErrMalformed
+	}
+}
+
+// IsExpired reports whether a PAT that expires at the given time (in seconds
+// since the epoch) has expired. DynamoDB's TTL can take a while to delete
+// expired PATs, so they must be checked for. PATs created before PATs could
+// expire have no expiry, so a zero expiry never expires.
+func IsExpired(expiresAt int64, now time.Time) bool {
+	return expiresAt != 0 && now.Unix() >= expiresAt
+}
+
+// ParseScopes parses a comma-separated list of scopes, returning them sorted
+// and without duplicates.
+func ParseScopes(list string) ([]string, error) {
+	scopes := []string{}
+	seen := map[string]bool{}
+	for _, scope := range strings.Split(list, ",") {
+		scope = strings.TrimSpace(scope)
+		if len(scope) == 0 || seen[scope] {
+			continue
+		}
+		if !isScope(scope) {
+			return nil, fmt.Errorf("%w: '%s'", ErrUnknownScope, scope)
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+
+	sort.Strings(scopes)
+	return scopes, nil
+}
+
+// HasScope reports whether a PAT that was granted the given scopes has the
+// required scope. PATs created before they were scoped have no scopes, and are
+// treated as having the LegacyScopes.
+func HasScope(granted []string, required string) bool {
+	if granted == nil {
+		granted = LegacyScopes
+	}
+	for _, scope := range granted {
+		if scope == required {
+			return true
+		}
+	}
+	return false
+}
+
+// Hash returns the digest that a PAT is stored under, and identified by.
+func Hash(pat string) string {
+	digest := sha256.Sum256([]byte(pat))
+	return hex.EncodeToString(digest[:])
+}
+
+// Redact returns a prefix of a PAT (or digest) that's safe to log.
+func Redact(pat string) string {
+	if len(pat) <= RedactedLength {
+		return pat
+	}
+	return pat[:RedactedLength] + "..."
+}
+
+// ContextPatId returns the ID of the PAT that the authorizer Lambda passed on in
+// a request's context, or false if the request wasn't authorised with a PAT.
+func ContextPatId(authorizer map[string]interface{}) (string, bool) {
+	patId, ok := authorizer[ContextPatIdKey].(string)
+	return patId, ok && len(patId) > 0
+}
+
+// ContextScopes returns the scopes that the authorizer Lambda passed on in a
+// request's context, or false if the request wasn't authorised with a PAT.
+func ContextScopes(authorizer map[string]interface{}) ([]string, bool) {
+	if _, ok := ContextPatId(authorizer); !ok {
+		return nil, false
+	}
+	scopes, _ := authorizer[ContextScopesKey].(string)
+	return strings.Split(scopes, ","), true
+}
+
+func isScope(scope string) bool {
+	for _, known := range Scopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+// checksum returns the base62-encoded CRC32 of the random part of a PAT,
+// padded to ChecksumLength characters.
+func checksum(random string) string {
+	value := crc32.ChecksumIEEE([]byte(random))
+	encoded := make([]byte, ChecksumLength)
+	for i := ChecksumLength - 1; i >= 0; i-- {
+		encoded[i] = base62Alphabet[value%uint32(len(base62Alphabet))]
+		value /= uint32(len(base62Alphabet))
+	}
+	return string(encoded)
+}
+
+func isBase62(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune(base62Alphabet, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isAcronym allows for acronyms containing hyphens and underscores, as
+// resource names can.
+func isAcronym(s string) bool {
+	return isBase62(strings.NewReplacer("-", "", "_", "").Replace(s))
+}
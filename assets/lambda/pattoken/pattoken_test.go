@@ -0,0 +1,232 @@
+package pattoken
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerate(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		pat, err := Generate("zaas")
+		if err != nil {
+			t.Fatalf("Generate() returned an error: %s", err)
+		}
+		if !strings.HasPrefix(pat, Prefix("zaas")) {
+			t.Errorf("Generate() = %s, want the prefix %s", pat, Prefix("zaas"))
+		}
+		if len(pat) != len(Prefix("zaas"))+RandomLength+ChecksumLength {
+			t.Errorf("Generate() = %s, which is %d characters long", pat, len(pat))
+		}
+		if err := Validate(pat); err != nil {
+			t.Errorf("Validate(%s) = %s, want nil", pat, err)
+		}
+		if seen[pat] {
+			t.Errorf("Generate() returned %s twice", pat)
+		}
+		seen[pat] = true
+	}
+}
+
+func TestValidate(t *testing.T) {
+	pat, err := Generate("my-stack")
+	if err != nil {
+		t.Fatalf("Generate() returned an error: %s", err)
+	}
+
+	// Change one character of the random part, keeping it base62
+	typo := []byte(pat)
+	index := len(Prefix("my-stack"))
+	if typo[index] == 'a' {
+		typo[index] = 'b'
+	} else {
+		typo[index] = 'a'
+	}
+
+	tests := []struct {
+		name string
+		pat  string
+		want error
+	}{
+		{"valid", pat, nil},
+		{"legacy", "zaas_pat_" + strings.Repeat("aB3", 21) + "x", nil},
+		{"typo", string(typo), ErrChecksum},
+		{"no acronym", strings.TrimPrefix(pat, "my-stack"), ErrMalformed},
+		{"no separator", strings.Replace(pat, Separator, "_", 1), ErrMalformed},
+		{"too short", pat[:len(pat)-1], ErrMalformed},
+		{"not base62", pat[:len(pat)-1] + "!", ErrMalformed},
+		{"empty", "", ErrMalformed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validate(test.pat)
+			if !errors.Is(err, test.want) {
+				t.Errorf("Validate(%s) = %v, want %v", test.pat, err, test.want)
+			}
+		})
+	}
+}
+
+func TestIsExpired(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name      string
+		expiresAt int64
+		want      bool
+	}{
+		{"no expiry", 0, false},
+		{"future", now.Unix() + 1, false},
+		{"now", now.Unix(), true},
+		{"past", now.Unix() - 1, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := IsExpired(test.expiresAt, now)
+			if got != test.want {
+				t.Errorf("IsExpired(%d) = %t, want %t", test.expiresAt, got, test.want)
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	// The SHA-256 test vector from FIPS 180-2.
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := Hash("abc"); got != want {
+		t.Errorf("Hash(abc) = %s, want %s", got, want)
+	}
+
+	pat, err := Generate("xaas")
+	if err != nil {
+		t.Fatalf("Generate() returned an error: %s", err)
+	}
+	if Hash(pat) != Hash(pat) {
+		t.Errorf("Hash(%s) isn't stable", pat)
+	}
+	if strings.Contains(Hash(pat), pat) {
+		t.Errorf("Hash(%s) contains the PAT", pat)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		pat  string
+		want string
+	}{
+		{"empty", "", ""},
+		{"short", "xaas_pat_", "xaas_pat_"},
+		{"exact", "xaas_pat_abc", "xaas_pat_abc"},
+		{"long", "xaas_pat_abcdef", "xaas_pat_abc..."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Redact(test.pat)
+			if got != test.want {
+				t.Errorf("Redact(%s) = %s, want %s", test.pat, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []string
+		wantErr error
+	}{
+		{"empty", "", []string{}, nil},
+		{"one", "facts:write", []string{"facts:write"}, nil},
+		{"read", "facts:read", []string{"facts:read"}, nil},
+		{"sorted", "images:write, facts:write", []string{"facts:write", "images:write"}, nil},
+		{"duplicates", "facts:write,facts:write,", []string{"facts:write"}, nil},
+		{"unknown", "facts:write,facts:delete", nil, ErrUnknownScope},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseScopes(test.list)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("ParseScopes(%s) returned %v, want %v", test.list, err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseScopes(%s) = %v, want %v", test.list, got, test.want)
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  []string
+		required string
+		want     bool
+	}{
+		{"granted", []string{ScopeFactsWrite}, ScopeFactsWrite, true},
+		{"not granted", []string{ScopeFactsWrite}, ScopeImagesWrite, false},
+		{"none granted", []string{}, ScopeFactsWrite, false},
+		{"unscoped", nil, ScopeFactsWrite, true},
+		{"unscoped admin", nil, ScopePatsAdmin, false},
+		{"default", DefaultScopes, ScopeFactsRead, true},
+		{"default write", DefaultScopes, ScopeFactsWrite, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := HasScope(test.granted, test.required)
+			if got != test.want {
+				t.Errorf("HasScope(%v, %s) = %t, want %t", test.granted, test.required, got, test.want)
+			}
+		})
+	}
+}
+
+func TestContextScopes(t *testing.T) {
+	scopes, ok := ContextScopes(map[string]interface{}{
+		ContextPatIdKey:  "0123456789abcdef",
+		ContextScopesKey: "facts:write,images:write",
+	})
+	if !ok {
+		t.Fatalf("ContextScopes() = false, want true")
+	}
+	if !reflect.DeepEqual(scopes, []string{"facts:write", "images:write"}) {
+		t.Errorf("ContextScopes() = %v, want [facts:write images:write]", scopes)
+	}
+
+	_, ok = ContextScopes(map[string]interface{}{
+		ContextPatIdKey: "",
+	})
+	if ok {
+		t.Errorf("ContextScopes() with an empty patId = true, want false")
+	}
+
+	_, ok = ContextScopes(map[string]interface{}{})
+	if ok {
+		t.Errorf("ContextScopes() of an empty context = true, want false")
+	}
+}
+
+func TestParseBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"Bearer zaas_pat_abc", "zaas_pat_abc"},
+		{"bearer zaas_pat_abc", "zaas_pat_abc"},
+		{"BEARER  zaas_pat_abc ", "zaas_pat_abc"},
+		{"Bearer: zaas_pat_abc", "zaas_pat_abc"},
+		{"zaas_pat_abc", "zaas_pat_abc"},
+		{"Basic zaas_pat_abc", "Basic zaas_pat_abc"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		got := ParseBearerToken(test.header)
+		if got != test.want {
+			t.Errorf("ParseBearerToken(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
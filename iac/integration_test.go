@@ -190,11 +190,11 @@ func validateResourceCounts(t *testing.T, stack integration.RuntimeValidationSta
 		"aws:apigateway/stage:Stage":                             1,
 		"aws:dynamodb/table:Table":                               2,
 		"aws:dynamodb/tableItem:TableItem":                       dynamicCountPlaceholder,
-		"aws:iam/role:Role":                                      3,
-		"aws:iam/rolePolicy:RolePolicy":                          3,
-		"aws:iam/rolePolicyAttachment:RolePolicyAttachment":      3,
-		"aws:lambda/function:Function":                           3,
-		"aws:lambda/permission:Permission":                       3,
+		"aws:iam/role:Role":                                      4,
+		"aws:iam/rolePolicy:RolePolicy":                          6,
+		"aws:iam/rolePolicyAttachment:RolePolicyAttachment":      4,
+		"aws:lambda/function:Function":                           4,
+		"aws:lambda/permission:Permission":                       14,
 		"aws:s3/bucket:Bucket":                                   1,
 		"aws:s3/bucketObject:BucketObject":                       dynamicCountPlaceholder,
 		"aws:s3/bucketPolicy:BucketPolicy":                       1,
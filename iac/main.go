@@ -2,61 +2,334 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/url"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
 
 	"github.com/pulumi/pulumi-aws-apigateway/sdk/go/apigateway"
 
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/cloudfront"
 	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/dynamodb"
 	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/iam"
 	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/lambda"
 	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/s3"
+	"github.com/pulumi/pulumi-aws/sdk/v5/go/aws/ssm"
+
+	_ "golang.org/x/image/webp"
+	"gopkg.in/yaml.v3"
 )
 
 var parentFolderPath string
 var assetFolderPath string
-var animalName string
+var animals []Animal
 var acronym string
-var animalAssetFolderPath string
-var animalImageFolderPath string
 var imageMetadataFile string
-var imageMetadataPath string
-var factFile string
+var imageManifestFile string
+var imageUploadsManifestFile string
 var lambdaFolder string
 var lambdaZipSuffix string
+var patTable *dynamodb.Table
+var assetBucketAccess string
+var presignExpirySeconds int
+var cdnEnabled bool
+var cdnDomain string
+var patLifetimeDaysMax int
+var patAuthorizer *lambda.Function
+var patAuthorizerCacheSeconds int
+var assetBucket *s3.Bucket
 var createdInfrastructure Infrastructure
 
-// TODO: Break this down into several types
-type MetadataImageList map[string]map[string]map[string]string
+// The assets bucket is public unless the `assetBucketAccess` config value is
+// set to `private`, in which case the images Lambda hands out presigned URLs
+// that expire after `presignExpirySeconds`.
+const assetBucketAccessPublic = "public"
+const assetBucketAccessPrivate = "private"
+// Presigned URLs can last for at most a week, when signed with SigV4.
+const presignExpirySecondsDefault = 900
+const presignExpirySecondsMax = 604800
+
+// PATs expire after the number of days asked for when they're created, which is
+// capped at the `patLifetimeDaysMax` config value. Expired PATs are deleted by
+// DynamoDB's TTL, using the expiry attribute.
+const patLifetimeDaysMaxDefault = 90
+const patExpiryAttribute = "ExpiresAt"
+
+// API Gateway can cache the authorizer Lambda's verdict on each PAT for
+// `patAuthorizerCacheSeconds`, up to the most that API Gateway allows. The cache
+// is off by default, so that deleted and expired PATs stop working immediately,
+// as the cached verdict isn't tied to the PAT's expiry.
+const patAuthorizerCacheSecondsDefault = 0
+const patAuthorizerCacheSecondsMax = 3600
+
+// The CloudFront distribution is only created when the `cdn` config value is
+// set to true. Responses from the facts Lambda are cached briefly, as facts can
+// be changed through the API; images are cached for as long as CloudFront
+// allows, as their keys change with their contents.
+const cdnFactsTtlDefault = 60
+const cdnFactsTtlMax = 300
+const cdnImagesTtlDefault = 86400
+const cdnImagesTtlMax = 31536000
+
+// The headers forwarded to the REST API by the CloudFront distribution. Every
+// other header is stripped, and left out of the cache key.
+var cdnForwardedHeaders = []string{
+	"Accept",
+	"Accept-Language",
+	"Authorization",
+	"Content-Type",
+}
+
+// The placeholder that the images Lambda replaces with the requested animal's
+// name, to find the prefix that its images are stored under.
+const animalPlaceholder = "{animal}"
+
+// The prefix, in the assets bucket, under which the images Lambda caches the
+// images it has resized.
+const derivedImagePrefix = "derived"
+
+// The language that facts are written in. Translations are read from files
+// named after their language code, e.g. `facts.fr.txt`.
+const defaultFactLanguage = "en"
+
+var languageCodePattern = regexp.MustCompile(`^[a-z]{2,3}(?:-[a-z0-9]{2,8})*$`)
+var factTranslationFilePattern = regexp.MustCompile(`^facts\.([a-z]{2,3}(?:-[a-z0-9]{2,8})*)\.txt$`)
+
+// The structured fact files, in order of preference. If an animal has none of
+// these, its facts are read from `facts.txt` instead.
+var structuredFactFiles = []string{"facts.yaml", "facts.yml", "facts.json"}
+
+// Facts created through the API are allocated FactIds from this value upwards
+// by the facts Lambda, so seeded facts must stay below it.
+const runtimeFactIdStart = 1000000
+
+// The FactId of the bookkeeping item that records how many facts were seeded.
+// Negative FactIds are reserved by the facts Lambda, and never served.
+const seedIndexFactId = -2
+
+// Images are stored under a key derived from their contents, so they can be
+// cached forever. The manifest changes with every deploy, so mustn't be cached.
+const imageCacheControl = "public, max-age=31536000, immutable"
+const manifestCacheControl = "no-cache"
+
+// The length of the (hex-encoded) SHA-256 prefix used in image keys and
+// resource names.
+const imageKeyHashLength = 32
+
+// The file extensions of the images that are deployed. Every other file, and
+// every hidden file or folder, is skipped.
+var imageFileExtensions = []string{".gif", ".jpeg", ".jpg", ".png", ".webp"}
+
+// S3 allows each object at most 10 tags, with keys of up to 128 characters and
+// values of up to 256 characters. Keys and values may only contain letters,
+// numbers, spaces and `_ . : / = + - @`. The images Lambda applies the same
+// rules to uploaded images.
+const s3TagCountMax = 10
+const s3TagKeyLengthMax = 128
+const s3TagValueLengthMax = 256
+
+var s3TagPattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// ImageMetadataFile is the structure of an animal's `metadata.json`, which
+// describes each of the animal's images, keyed by file name.
+type ImageMetadataFile struct {
+	Images map[string]ImageMetadata `json:"images"`
+}
+
+// ImageMetadata describes where an image came from. Each field is stored as
+// one of the image's S3 object tags.
+type ImageMetadata struct {
+	Source      string `json:"source"`
+	Attribution string `json:"attribution"`
+	Description string `json:"description"`
+}
+
+// ImageManifest lists an animal's images, and their tags, so that the images
+// Lambda doesn't need to list the bucket to find them.
+type ImageManifest struct {
+	Images []ImageManifestEntry `json:"images"`
+}
+
+type ImageManifestEntry struct {
+	Name string            `json:"name"`
+	Key  string            `json:"key"`
+	Tags map[string]string `json:"tags"`
+	ImageDetails
+}
+
+// ImageDetails are read from each image at deploy time, so that clients can
+// lay out an image before it has loaded, and check that it arrived intact.
+type ImageDetails struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Format string `json:"format"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Animal holds the name of one of the animals served by the stack, and the
+// paths to its assets.
+type Animal struct {
+	Name              string
+	AssetFolderPath   string
+	ImageFolderPath   string
+	ImageMetadataPath string
+	ImageManifestPath string
+	FactFile          string
+	ImageMetadata     map[string]ImageMetadata
+	ImageDetails      map[string]ImageDetails
+}
+
+// FactFile is the structure of a `facts.yaml` or `facts.json` file.
+type FactFile struct {
+	Facts []FactFileEntry `yaml:"facts" json:"facts"`
+}
+
+// FactFileEntry is a single fact in a structured fact file. Facts without an
+// ID are numbered by their position in the file, like the lines of
+// `facts.txt`. Giving facts an ID means that they can be re-ordered, or have
+// facts added before them, without changing.
+type FactFileEntry struct {
+	Id           *int              `yaml:"id" json:"id"`
+	Text         string            `yaml:"text" json:"text"`
+	Source       string            `yaml:"source" json:"source"`
+	Tags         []string          `yaml:"tags" json:"tags"`
+	Translations map[string]string `yaml:"translations" json:"translations"`
+}
 
 type Fact struct {
-	FactId int    `dynamodbav:"FactId" json:"id"`
-	Text   string `dynamodbav:"Text" json:"text"`
+	Animal       string            `dynamodbav:"Animal" json:"animal"`
+	FactId       int               `dynamodbav:"FactId" json:"id"`
+	Text         string            `dynamodbav:"Text" json:"text"`
+	Lang         string            `dynamodbav:"Lang" json:"lang"`
+	Source       string            `dynamodbav:"Source,omitempty" json:"source,omitempty"`
+	Tags         []string          `dynamodbav:"Tags,omitempty" json:"tags,omitempty"`
+	Translations map[string]string `dynamodbav:"Translations,omitempty" json:"-"`
 }
 
-func (fact Fact) MarshalToDynamoDB() string {
-	return fmt.Sprintf(
-		"{\n  \"FactId\": {\"N\": \"%d\"},\n  \"Text\": {\"S\": \"%s\"}\n}\n",
-		fact.FactId,
-		fact.Text,
-	)
+// DynamoDBValue is a single attribute value, in the DynamoDB JSON format that
+// dynamodb.TableItem expects, e.g. `{"S": "otter"}`.
+type DynamoDBValue map[string]interface{}
+
+func dynamoDBString(value string) DynamoDBValue {
+	return DynamoDBValue{"S": value}
+}
+
+func dynamoDBNumber(value int) DynamoDBValue {
+	return DynamoDBValue{"N": strconv.Itoa(value)}
+}
+
+func dynamoDBNumberSet(values []int) DynamoDBValue {
+	numbers := make([]string, 0, len(values))
+	for _, value := range values {
+		numbers = append(numbers, strconv.Itoa(value))
+	}
+	return DynamoDBValue{"NS": numbers}
+}
+
+func dynamoDBStringList(values []string) DynamoDBValue {
+	list := make([]DynamoDBValue, 0, len(values))
+	for _, value := range values {
+		list = append(list, dynamoDBString(value))
+	}
+	return DynamoDBValue{"L": list}
+}
+
+func dynamoDBStringMap(values map[string]string) DynamoDBValue {
+	items := make(map[string]DynamoDBValue, len(values))
+	for key, value := range values {
+		items[key] = dynamoDBString(value)
+	}
+	return DynamoDBValue{"M": items}
+}
+
+// marshalDynamoDBItem encodes an item as DynamoDB JSON. The keys are sorted
+// by encoding/json, so the output doesn't change between deployments.
+func marshalDynamoDBItem(item map[string]DynamoDBValue) (string, error) {
+	itemJson, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(itemJson), nil
+}
+
+func (fact Fact) MarshalToDynamoDB() (string, error) {
+	item := map[string]DynamoDBValue{
+		"Animal": dynamoDBString(fact.Animal),
+		"FactId": dynamoDBNumber(fact.FactId),
+		"Text":   dynamoDBString(fact.Text),
+		"Lang":   dynamoDBString(fact.Lang),
+	}
+	if len(fact.Source) > 0 {
+		item["Source"] = dynamoDBString(fact.Source)
+	}
+	if len(fact.Tags) > 0 {
+		item["Tags"] = dynamoDBStringList(fact.Tags)
+	}
+	if len(fact.Translations) > 0 {
+		item["Translations"] = dynamoDBStringMap(fact.Translations)
+	}
+	return marshalDynamoDBItem(item)
+}
+
+// FactIndex is the bookkeeping item that tells the facts Lambda which facts
+// were seeded, so that it can pick one at random without scanning the table.
+// The facts Lambda adds the FactIds of any seeded facts deleted at runtime to
+// it. SeedVersion is a digest of every seeded fact, so that the item is
+// written again, dropping those FactIds, whenever a deploy seeds facts that
+// might have been deleted.
+type FactIndex struct {
+	Animal      string `dynamodbav:"Animal"`
+	FactId      int    `dynamodbav:"FactId"`
+	FactCount   int    `dynamodbav:"FactCount"`
+	FactIds     []int  `dynamodbav:"FactIds,numberset"`
+	SeedVersion string `dynamodbav:"SeedVersion"`
+}
+
+func (index FactIndex) MarshalToDynamoDB() (string, error) {
+	item := map[string]DynamoDBValue{
+		"Animal":    dynamoDBString(index.Animal),
+		"FactId":    dynamoDBNumber(index.FactId),
+		"FactCount": dynamoDBNumber(index.FactCount),
+	}
+	if len(index.SeedVersion) > 0 {
+		item["SeedVersion"] = dynamoDBString(index.SeedVersion)
+	}
+	// DynamoDB doesn't allow empty sets.
+	if len(index.FactIds) > 0 {
+		item["FactIds"] = dynamoDBNumberSet(index.FactIds)
+	}
+	return marshalDynamoDBItem(item)
 }
 
 type Infrastructure struct {
 	DdbTableItems []*dynamodb.TableItem
 	DdbTables     []*dynamodb.Table
+	Distributions []*cloudfront.Distribution
 	Lambdas       []*lambda.Function
 	RestApis      []*apigateway.RestAPI
 	S3Buckets     []*s3.Bucket
 	S3Objects     []*s3.BucketObject
+	SsmParameters []*ssm.Parameter
 }
 
 type LambdaInfra struct {
@@ -66,9 +339,12 @@ type LambdaInfra struct {
 	Routes []apigateway.RouteArgs
 }
 
+// LambdaRoute is a route served by a Lambda function. Authorised routes can
+// only be called with a valid PAT, which is checked by the authorizer Lambda.
 type LambdaRoute struct {
-	Path   string
-	Method apigateway.Method
+	Path       string
+	Method     apigateway.Method
+	Authorised bool
 }
 
 type RolePolicy struct {
@@ -76,20 +352,122 @@ type RolePolicy struct {
 	Document   pulumi.StringOutput
 }
 
-func initStrings(ctx *pulumi.Context) {
+func initStrings(ctx *pulumi.Context) error {
 	cwd, _ := os.Getwd()
 	parentFolderPath = path.Join(cwd, "..") + "/"
 	assetFolderPath = path.Join(cwd, "..", "assets")
 	conf := config.New(ctx, "")
-	animalName = conf.Require("animal")
-	acronym = fmt.Sprintf("%caas", animalName[0])
-	animalAssetFolderPath = path.Join(assetFolderPath, "animals", animalName)
-	animalImageFolderPath = path.Join(animalAssetFolderPath, "images")
+	acronym = conf.Get("acronym")
+	assetBucketAccess = conf.Get("assetBucketAccess")
+	if len(assetBucketAccess) == 0 {
+		assetBucketAccess = assetBucketAccessPublic
+	}
+	if assetBucketAccess != assetBucketAccessPublic && assetBucketAccess != assetBucketAccessPrivate {
+		return fmt.Errorf(
+			"assetBucketAccess must be '%s' or '%s', got: '%s'",
+			assetBucketAccessPublic,
+			assetBucketAccessPrivate,
+			assetBucketAccess,
+		)
+	}
+	presignExpirySeconds = conf.GetInt("presignExpirySeconds")
+	if presignExpirySeconds == 0 {
+		presignExpirySeconds = presignExpirySecondsDefault
+	}
+	if presignExpirySeconds < 0 || presignExpirySeconds > presignExpirySecondsMax {
+		return fmt.Errorf(
+			"presignExpirySeconds must be between 1 and %d, got: %d",
+			presignExpirySecondsMax,
+			presignExpirySeconds,
+		)
+	}
+	cdnEnabled = conf.GetBool("cdn")
+	// The Lambdas hand out links on the distribution's own domain name,
+	// unless an alternate domain name pointing at it is given.
+	if cdnEnabled {
+		cdnDomain = conf.Get("cdnDomain")
+	}
+	patLifetimeDaysMax = conf.GetInt("patLifetimeDaysMax")
+	if patLifetimeDaysMax == 0 {
+		patLifetimeDaysMax = patLifetimeDaysMaxDefault
+	}
+	if patLifetimeDaysMax < 0 {
+		return fmt.Errorf(
+			"patLifetimeDaysMax must be positive, got: %d",
+			patLifetimeDaysMax,
+		)
+	}
+	// Unlike the other numbers, 0 is a meaningful setting.
+	cacheSeconds, cacheErr := conf.TryInt("patAuthorizerCacheSeconds")
+	patAuthorizerCacheSeconds = cacheSeconds
+	if cacheErr != nil {
+		patAuthorizerCacheSeconds = patAuthorizerCacheSecondsDefault
+	}
+	if patAuthorizerCacheSeconds < 0 || patAuthorizerCacheSeconds > patAuthorizerCacheSecondsMax {
+		return fmt.Errorf(
+			"patAuthorizerCacheSeconds must be between 0 and %d, got: %d",
+			patAuthorizerCacheSecondsMax,
+			patAuthorizerCacheSeconds,
+		)
+	}
 	imageMetadataFile = "metadata.json"
-	imageMetadataPath = path.Join(animalImageFolderPath, imageMetadataFile)
-	factFile = path.Join(animalAssetFolderPath, "facts.txt")
+	imageManifestFile = "manifest.json"
+	imageUploadsManifestFile = "uploads.json"
 	lambdaFolder = path.Join(assetFolderPath, "lambda")
 	lambdaZipSuffix = "bin/main.zip"
+
+	// The animals are supplied as a comma-separated list. Stacks configured
+	// before multiple animals were supported will only have `animal` set.
+	animalList := conf.Get("animals")
+	if len(animalList) == 0 {
+		animalList = conf.Require("animal")
+	}
+
+	animals = make([]Animal, 0)
+	for _, animalName := range strings.Split(animalList, ",") {
+		animalName = strings.TrimSpace(animalName)
+		if len(animalName) == 0 || animalName != strings.ToLower(animalName) {
+			return fmt.Errorf(
+				"animal names must be lower-case and non-empty, got: '%s'",
+				animalName,
+			)
+		}
+		for _, animal := range animals {
+			if animal.Name == animalName {
+				return fmt.Errorf("animal '%s' is configured twice", animalName)
+			}
+		}
+
+		animalAssetFolderPath := path.Join(assetFolderPath, "animals", animalName)
+		animalImageFolderPath := path.Join(animalAssetFolderPath, "images")
+		animals = append(animals, Animal{
+			Name:              animalName,
+			AssetFolderPath:   animalAssetFolderPath,
+			ImageFolderPath:   animalImageFolderPath,
+			ImageMetadataPath: path.Join(animalImageFolderPath, imageMetadataFile),
+			ImageManifestPath: path.Join(animalAssetFolderPath, imageManifestFile),
+			FactFile:          path.Join(animalAssetFolderPath, "facts.txt"),
+		})
+	}
+
+	// Stacks deployed before the `acronym` config value existed were prefixed
+	// with the first letter of their animal, e.g. `kaas`. Keep that prefix so
+	// existing resources aren't replaced.
+	if len(acronym) == 0 {
+		acronym = fmt.Sprintf("%caas", animals[0].Name[0])
+	}
+
+	return nil
+}
+
+// getAnimalNames returns the names of the configured animals, as the
+// comma-separated list expected by the Lambda functions.
+func getAnimalNames() string {
+	animalNames := make([]string, 0, len(animals))
+	for _, animal := range animals {
+		animalNames = append(animalNames, animal.Name)
+	}
+	return strings.Join(animalNames, ",")
 }
 
 // As we can't declare const arrays, we use the functions below.
@@ -102,22 +480,37 @@ func getLambdaDetails() map[string]func(ctx *pulumi.Context) (LambdaInfra, error
 }
 
 func createLambdaFacts(ctx *pulumi.Context) (LambdaInfra, error) {
-	bucket, err := deployPublicBucket(
-		ctx,
-		fmt.Sprintf("%s-s3-assets", acronym),
-	)
+	var bucket *s3.Bucket
+	var err error
+	if assetBucketAccess == assetBucketAccessPrivate {
+		bucket, err = deployPrivateBucket(
+			ctx,
+			fmt.Sprintf("%s-s3-assets", acronym),
+		)
+	} else {
+		bucket, err = deployPublicBucket(
+			ctx,
+			fmt.Sprintf("%s-s3-assets", acronym),
+		)
+	}
 	if err != nil {
 		return LambdaInfra{}, err
 	}
+	assetBucket = bucket
 
-	// Deploy the images in the image folder to the S3 bucket
-	err = addFolderContentsToS3(ctx, animalImageFolderPath, bucket)
-	if err != nil {
-		return LambdaInfra{}, err
+	// Deploy the images in each animal's image folder to the S3 bucket
+	for _, animal := range animals {
+		err = addFolderContentsToS3(ctx, animal, bucket)
+		if err != nil {
+			return LambdaInfra{}, err
+		}
 	}
 
 	// Create a list of IAM policies required by the "images" lambda.
-	// Specifically, we need to be able to read
+	// Specifically, we need to be able to read. When the bucket is private,
+	// the presigned URLs handed out by the lambda are signed with its role, so
+	// it's the role's s3:GetObject permission that lets clients download the
+	// images.
 	policies := []RolePolicy{
 		{
 			NameSuffix: "s3-read-policy",
@@ -130,39 +523,129 @@ func createLambdaFacts(ctx *pulumi.Context) (LambdaInfra, error) {
 							"Effect": "Allow",
 							"Action": [
 								"s3:GetBucketLocation",
-								"s3:GetObject",
-								"s3:GetObjectTagging",
 								"s3:ListBucket"
 							],
-							"Resource": [
-								"%s/*",
-								"%s"
-							]
+							"Resource": "%s"
+						},
+						{
+							"Sid": "ReadImages",
+							"Effect": "Allow",
+							"Action": [
+								"s3:GetObject",
+								"s3:GetObjectTagging"
+							],
+							"Resource": "%s/*"
+						}
+					]
+				}`,
+				bucket.Arn,
+				bucket.Arn,
+			),
+		},
+		{
+			// Resized images are cached under the derived prefix, and
+			// uploaded images are written (with their tags) alongside the
+			// deployed images
+			NameSuffix: "s3-write-policy",
+			Document: pulumi.Sprintf(
+				`{
+					"Version": "2012-10-17",
+					"Statement": [
+						{
+							"Sid": "PutDerivedImages",
+							"Effect": "Allow",
+							"Action": [
+								"s3:PutObject"
+							],
+							"Resource": "%s/%s/*"
+						},
+						{
+							"Sid": "PutUploadedImages",
+							"Effect": "Allow",
+							"Action": [
+								"s3:PutObject",
+								"s3:PutObjectTagging"
+							],
+							"Resource": "%s/%s/*"
 						}
 					]
 				}`,
 				bucket.Arn,
+				derivedImagePrefix,
 				bucket.Arn,
+				strings.TrimPrefix(
+					path.Join(assetFolderPath, "animals"),
+					parentFolderPath,
+				),
 			),
 		},
 	}
 
+	// Links on the CloudFront distribution are read from its parameter.
+	policies = append(policies, getCdnDomainPolicies()...)
+
 	functionInfra, err := deployLambdaFunction(
 		ctx,
 		"images",
 		policies,
-		pulumi.StringMap{
-			"IMAGES_BUCKET_NAME": bucket.Bucket,
+		withCdnDomainParameter(pulumi.StringMap{
+			"ANIMALS":                       pulumi.String(getAnimalNames()),
+			"IMAGES_BUCKET_NAME":            bucket.Bucket,
+			"IMAGES_BUCKET_ACCESS":          pulumi.String(assetBucketAccess),
+			"IMAGES_PRESIGN_EXPIRY_SECONDS": pulumi.String(strconv.Itoa(presignExpirySeconds)),
 			"IMAGES_OBJECT_PREFIX": pulumi.String(
 				strings.TrimPrefix(
-					animalImageFolderPath,
+					path.Join(
+						assetFolderPath,
+						"animals",
+						animalPlaceholder,
+						"images",
+					),
+					parentFolderPath,
+				) + "/",
+			),
+			// The manifest sits beside, rather than inside, the images folder
+			// so that it isn't mistaken for an image.
+			"IMAGES_MANIFEST_KEY": pulumi.String(
+				strings.TrimPrefix(
+					path.Join(
+						assetFolderPath,
+						"animals",
+						animalPlaceholder,
+						imageManifestFile,
+					),
 					parentFolderPath,
 				),
 			),
-		},
+			"IMAGES_UPLOADS_MANIFEST_KEY": pulumi.String(
+				strings.TrimPrefix(
+					path.Join(
+						assetFolderPath,
+						"animals",
+						animalPlaceholder,
+						imageUploadsManifestFile,
+					),
+					parentFolderPath,
+				),
+			),
+			"IMAGES_DERIVED_PREFIX": pulumi.String(
+				path.Join(derivedImagePrefix, "animals", animalPlaceholder, "images") + "/",
+			),
+		}),
 		[]LambdaRoute{
 			{
-				Path:   "/images",
+				Path:   "/{animal}/images",
+				Method: apigateway.MethodGET,
+			},
+			{
+				// Requires a PAT
+				Path:       "/{animal}/images",
+				Method:     apigateway.MethodPOST,
+				Authorised: true,
+			},
+			{
+				// Returns a resized copy of one of the images
+				Path:   "/{animal}/images/{name}",
 				Method: apigateway.MethodGET,
 			},
 		},
@@ -181,12 +664,17 @@ func createLambdaImages(ctx *pulumi.Context) (LambdaInfra, error) {
 		fmt.Sprintf("%s-ddb-facts", acronym),
 		&dynamodb.TableArgs{
 			Attributes: dynamodb.TableAttributeArray{
+				&dynamodb.TableAttributeArgs{
+					Name: pulumi.String("Animal"),
+					Type: pulumi.String("S"),
+				},
 				&dynamodb.TableAttributeArgs{
 					Name: pulumi.String("FactId"),
 					Type: pulumi.String("N"),
 				},
 			},
-			HashKey:       pulumi.String("FactId"),
+			HashKey:       pulumi.String("Animal"),
+			RangeKey:      pulumi.String("FactId"),
 			BillingMode:   pulumi.String("PROVISIONED"),
 			ReadCapacity:  pulumi.Int(10),
 			WriteCapacity: pulumi.Int(10),
@@ -202,10 +690,14 @@ func createLambdaImages(ctx *pulumi.Context) (LambdaInfra, error) {
 		ddbTable,
 	)
 
-	// Deploy the facts to the DynamoDB table
-	err = addTextContentsToDdb(ctx, factFile, ddbTable)
-	if err != nil {
-		return LambdaInfra{}, err
+	// Deploy each animal's facts to the DynamoDB table. Facts created through
+	// the API are allocated FactIds from a separate range by the Lambda, so
+	// these items will never overwrite them.
+	for _, animal := range animals {
+		err = addTextContentsToDdb(ctx, animal, ddbTable)
+		if err != nil {
+			return LambdaInfra{}, err
+		}
 	}
 
 	policies := []RolePolicy{
@@ -219,6 +711,7 @@ func createLambdaImages(ctx *pulumi.Context) (LambdaInfra, error) {
 							"Sid": "DescribeQueryScanFactsTable",
 							"Effect": "Allow",
 							"Action": [
+								"dynamodb:BatchGetItem",
 								"dynamodb:DescribeTable",
 								"dynamodb:GetItem",
 								"dynamodb:Query",
@@ -231,20 +724,67 @@ func createLambdaImages(ctx *pulumi.Context) (LambdaInfra, error) {
 				ddbTable.Arn,
 			),
 		},
+		{
+			NameSuffix: "ddb-write-policy",
+			Document: pulumi.Sprintf(
+				`{
+					"Version": "2012-10-17",
+					"Statement": [
+						{
+							"Sid": "WriteFactsTable",
+							"Effect": "Allow",
+							"Action": [
+								"dynamodb:DeleteItem",
+								"dynamodb:PutItem",
+								"dynamodb:UpdateItem"
+							],
+							"Resource": "%s"
+						}
+					]
+				}`,
+				ddbTable.Arn,
+			),
+		},
 	}
 
+	// Links on the CloudFront distribution are read from its parameter.
+	policies = append(policies, getCdnDomainPolicies()...)
+
 	functionInfra, err := deployLambdaFunction(
 		ctx,
 		"facts",
 		policies,
-		pulumi.StringMap{
+		withCdnDomainParameter(pulumi.StringMap{
+			"ANIMALS":          pulumi.String(getAnimalNames()),
 			"FACTS_TABLE_NAME": ddbTable.Name,
-		},
+		}),
 		[]LambdaRoute{
+			// Serves random facts, specific facts (`?FactId=`), and paginated
+			// listings (`?limit=&cursor=`) of the whole table.
+			{
+				Path:   "/{animal}/facts",
+				Method: apigateway.MethodGET,
+			},
 			{
-				Path:   "/facts",
+				Path:   "/{animal}/facts/today",
 				Method: apigateway.MethodGET,
 			},
+			// Creating, updating and deleting facts requires a PAT.
+			{
+				Path:       "/{animal}/facts",
+				Method:     apigateway.MethodPOST,
+				Authorised: true,
+			},
+			{
+				Path:       "/{animal}/facts/{id}",
+				Method:     apigateway.MethodPUT,
+				Authorised: true,
+			},
+			{
+				Path:       "/{animal}/facts/{id}",
+				Method:     apigateway.MethodDELETE,
+				Authorised: true,
+			},
 		},
 	)
 	if err != nil {
@@ -254,7 +794,9 @@ func createLambdaImages(ctx *pulumi.Context) (LambdaInfra, error) {
 	return functionInfra, nil
 }
 
-func createLambdaPats(ctx *pulumi.Context) (LambdaInfra, error) {
+// deployPatTable creates the DynamoDB table that holds the PATs. It is created
+// ahead of the Lambda functions, as more than one of them needs to read it.
+func deployPatTable(ctx *pulumi.Context) error {
 	// Create a DynamoDB table
 	ddbTable, err := dynamodb.NewTable(
 		ctx,
@@ -270,10 +812,14 @@ func createLambdaPats(ctx *pulumi.Context) (LambdaInfra, error) {
 			BillingMode:   pulumi.String("PROVISIONED"),
 			ReadCapacity:  pulumi.Int(10),
 			WriteCapacity: pulumi.Int(10),
+			Ttl: &dynamodb.TableTtlArgs{
+				AttributeName: pulumi.String(patExpiryAttribute),
+				Enabled:       pulumi.Bool(true),
+			},
 		},
 	)
 	if err != nil {
-		return LambdaInfra{}, err
+		return err
 	}
 
 	// Add the resource to createdInfrastructure for testing purposes.
@@ -282,6 +828,76 @@ func createLambdaPats(ctx *pulumi.Context) (LambdaInfra, error) {
 		ddbTable,
 	)
 
+	patTable = ddbTable
+	return nil
+}
+
+// deployPatAuthorizer creates the Lambda function that checks the PAT supplied
+// to each authorised route. It must be created before the Lambda functions that
+// serve those routes.
+func deployPatAuthorizer(ctx *pulumi.Context) error {
+	// The authorizer also records when each PAT was last used, and migrates
+	// PATs stored in plaintext to their digests as they're used.
+	policies := []RolePolicy{
+		{
+			NameSuffix: "ddb-pats-policy",
+			Document: pulumi.Sprintf(
+				`{
+					"Version": "2012-10-17",
+					"Statement": [
+						{
+							"Sid": "CheckPatsTable",
+							"Effect": "Allow",
+							"Action": [
+								"dynamodb:DeleteItem",
+								"dynamodb:GetItem",
+								"dynamodb:PutItem",
+								"dynamodb:UpdateItem"
+							],
+							"Resource": "%s"
+						}
+					]
+				}`,
+				patTable.Arn,
+			),
+		},
+	}
+
+	functionInfra, err := deployLambdaFunction(
+		ctx,
+		"authorizer",
+		policies,
+		pulumi.StringMap{
+			"PAT_TABLE_NAME":        patTable.Name,
+			"PAT_LIFETIME_DAYS_MAX": pulumi.String(strconv.Itoa(patLifetimeDaysMax)),
+		},
+		[]LambdaRoute{},
+	)
+	if err != nil {
+		return err
+	}
+
+	patAuthorizer = functionInfra.Lambda
+	return nil
+}
+
+// getPatAuthorizerArgs returns the authorizer attached to each authorised
+// route. Every route shares the one authorizer, which API Gateway tells apart
+// by its name.
+func getPatAuthorizerArgs() apigateway.AuthorizerArgs {
+	authorizerName := fmt.Sprintf("%s-pat-authorizer", acronym)
+	cacheSeconds := float64(patAuthorizerCacheSeconds)
+	return apigateway.AuthorizerArgs{
+		AuthorizerName:               &authorizerName,
+		Type:                         pulumi.StringRef("token"),
+		ParameterName:                "Authorization",
+		ParameterLocation:            pulumi.StringRef("header"),
+		Handler:                      patAuthorizer,
+		AuthorizerResultTtlInSeconds: &cacheSeconds,
+	}
+}
+
+func createLambdaPats(ctx *pulumi.Context) (LambdaInfra, error) {
 	policies := []RolePolicy{
 		{
 			NameSuffix: "ddb-read-policy",
@@ -304,24 +920,53 @@ func createLambdaPats(ctx *pulumi.Context) (LambdaInfra, error) {
 						}
 					]
 				}`,
-				ddbTable.Arn,
+				patTable.Arn,
 			),
 		},
 	}
 
+	// Links on the CloudFront distribution are read from its parameter.
+	policies = append(policies, getCdnDomainPolicies()...)
+
 	functionInfra, err := deployLambdaFunction(
 		ctx,
 		"pats",
 		policies,
-		pulumi.StringMap{
-			"ACRONYM":        pulumi.String(acronym),
-			"PAT_TABLE_NAME": ddbTable.Name,
-		},
+		withCdnDomainParameter(pulumi.StringMap{
+			"ACRONYM":               pulumi.String(acronym),
+			"PAT_TABLE_NAME":        patTable.Name,
+			"PAT_LIFETIME_DAYS_MAX": pulumi.String(strconv.Itoa(patLifetimeDaysMax)),
+		}),
 		[]LambdaRoute{
 			{
 				Path:   "/pats",
 				Method: apigateway.MethodPOST,
 			},
+			// Creates a PAT with scopes beyond the defaults, each of which the
+			// supplied PAT must already have
+			{
+				Path:       "/pats/privileged",
+				Method:     apigateway.MethodPOST,
+				Authorised: true,
+			},
+			// Describes the PAT that's supplied
+			{
+				Path:       "/pats/self",
+				Method:     apigateway.MethodGET,
+				Authorised: true,
+			},
+			// Lists every PAT, which requires the `pats:admin` scope
+			{
+				Path:       "/pats",
+				Method:     apigateway.MethodGET,
+				Authorised: true,
+			},
+			// Deletes the PAT that's supplied. The pats Lambda checks the PAT
+			// itself, so that it can tell unknown PATs apart.
+			{
+				Path:   "/pats",
+				Method: apigateway.MethodDELETE,
+			},
 		},
 	)
 	if err != nil {
@@ -352,11 +997,15 @@ func createLambdaPats(ctx *pulumi.Context) (LambdaInfra, error) {
 // deployPublicBucket creates an s3.Bucket object, applies a permissive
 // PublicAccessBlock, and a public BucketPolicy.
 func deployPublicBucket(ctx *pulumi.Context, bucketName string) (*s3.Bucket, error) {
-	// Create an AWS resource (S3 Bucket)
+	// Create an AWS resource (S3 Bucket). The Lambda functions write objects
+	// that aren't managed here, such as resized and uploaded images, so the
+	// bucket is emptied when it's destroyed.
 	bucket, err := s3.NewBucket(
 		ctx,
 		bucketName,
-		&s3.BucketArgs{},
+		&s3.BucketArgs{
+			ForceDestroy: pulumi.Bool(true),
+		},
 	)
 	if err != nil {
 		return nil, err
@@ -417,10 +1066,52 @@ func deployPublicBucket(ctx *pulumi.Context, bucketName string) (*s3.Bucket, err
 	return bucket, nil
 }
 
-func compileLambdas() error {
-	// Build and zip the code
-	cmd := exec.Command("make")
-	cmd.Dir = lambdaFolder
+// deployPrivateBucket creates an s3.Bucket object, and blocks all public access
+// to it. Its objects can only be read through presigned URLs.
+func deployPrivateBucket(ctx *pulumi.Context, bucketName string) (*s3.Bucket, error) {
+	// Create an AWS resource (S3 Bucket). The Lambda functions write objects
+	// that aren't managed here, such as resized and uploaded images, so the
+	// bucket is emptied when it's destroyed.
+	bucket, err := s3.NewBucket(
+		ctx,
+		bucketName,
+		&s3.BucketArgs{
+			ForceDestroy: pulumi.Bool(true),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add the resource to createdInfrastructure for testing purposes.
+	createdInfrastructure.S3Buckets = append(
+		createdInfrastructure.S3Buckets,
+		bucket,
+	)
+
+	// Create a closed Public Access Block
+	_, err = s3.NewBucketPublicAccessBlock(
+		ctx,
+		fmt.Sprintf("%s-publicaccess-block", bucketName),
+		&s3.BucketPublicAccessBlockArgs{
+			Bucket:                bucket.ID(),
+			BlockPublicAcls:       pulumi.Bool(true),
+			BlockPublicPolicy:     pulumi.Bool(true),
+			IgnorePublicAcls:      pulumi.Bool(true),
+			RestrictPublicBuckets: pulumi.Bool(true),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return bucket, nil
+}
+
+func compileLambdas() error {
+	// Build and zip the code
+	cmd := exec.Command("make")
+	cmd.Dir = lambdaFolder
 	_, err := cmd.Output()
 	if err != nil {
 		return err
@@ -428,6 +1119,51 @@ func compileLambdas() error {
 	return nil
 }
 
+// getCdnDomainParameterName returns the name of the SSM parameter holding the
+// domain name of the CloudFront distribution.
+func getCdnDomainParameterName() string {
+	return fmt.Sprintf("/%s/cdn-domain", acronym)
+}
+
+// getCdnDomainPolicies returns the policies that let a Lambda read the
+// distribution's domain name, if there is a distribution. The parameter is
+// written after the Lambdas are created, so it's referred to by name.
+func getCdnDomainPolicies() []RolePolicy {
+	if !cdnEnabled {
+		return nil
+	}
+	return []RolePolicy{
+		{
+			NameSuffix: "ssm-cdn-policy",
+			Document: pulumi.Sprintf(
+				`{
+					"Version": "2012-10-17",
+					"Statement": [
+						{
+							"Sid": "ReadCdnDomainParameter",
+							"Effect": "Allow",
+							"Action": [
+								"ssm:GetParameter"
+							],
+							"Resource": "arn:aws:ssm:*:*:parameter%s"
+						}
+					]
+				}`,
+				getCdnDomainParameterName(),
+			),
+		},
+	}
+}
+
+// withCdnDomainParameter tells a Lambda where to find the distribution's
+// domain name, if there is a distribution.
+func withCdnDomainParameter(envVars pulumi.StringMap) pulumi.StringMap {
+	if cdnEnabled {
+		envVars["CDN_DOMAIN_PARAMETER"] = pulumi.String(getCdnDomainParameterName())
+	}
+	return envVars
+}
+
 func deployLambdaFunction(
 	// Arguments
 	ctx *pulumi.Context,
@@ -532,14 +1268,17 @@ func deployLambdaFunction(
 
 	apiGwRoutes := make([]apigateway.RouteArgs, 0)
 	for _, route := range routes {
-		apiGwRoutes = append(
-			apiGwRoutes,
-			apigateway.RouteArgs{
-				Path:         route.Path,
-				Method:       &route.Method,
-				EventHandler: function,
-			},
-		)
+		routeArgs := apigateway.RouteArgs{
+			Path:         route.Path,
+			Method:       &route.Method,
+			EventHandler: function,
+		}
+		if route.Authorised {
+			routeArgs.Authorizers = []apigateway.AuthorizerArgs{
+				getPatAuthorizerArgs(),
+			}
+		}
+		apiGwRoutes = append(apiGwRoutes, routeArgs)
 	}
 
 	infra := LambdaInfra{
@@ -551,54 +1290,321 @@ func deployLambdaFunction(
 	return infra, nil
 }
 
-func addFolderContentsToS3(ctx *pulumi.Context, directory string, s3Bucket *s3.Bucket) error {
-	// Get a list of all the files in the target folder
-	files, err := os.ReadDir(directory)
-	if err != nil {
-		return err
+// Tags returns the S3 object tags of an image. Empty fields are left out.
+func (metadata ImageMetadata) Tags() map[string]string {
+	tags := map[string]string{}
+	if len(metadata.Source) > 0 {
+		tags["source"] = metadata.Source
+	}
+	if len(metadata.Attribution) > 0 {
+		tags["attribution"] = metadata.Attribution
+	}
+	if len(metadata.Description) > 0 {
+		tags["description"] = metadata.Description
 	}
+	return tags
+}
 
-	// Open the metadata file
-	var metadata MetadataImageList
-	metadataJson, err := os.Open(imageMetadataPath)
+// loadImageMetadata reads and validates an animal's `metadata.json`. Every
+// image must have an entry, and every entry must have an image, a `source`
+// URL and an `attribution`, and fit within S3's tagging limits. All of the
+// problems found are reported together.
+func loadImageMetadata(animal Animal) (map[string]ImageMetadata, error) {
+	data, err := os.ReadFile(animal.ImageMetadataPath)
 	if err != nil {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"could not load the '%s' metadata file at: '%s'",
-			animalName,
-			imageMetadataPath,
+			animal.Name,
+			animal.ImageMetadataPath,
 		)
-	} else {
-		// Ensure that the metadata file is closed at the end of the function
-		defer metadataJson.Close()
+	}
+
+	var metadataFile ImageMetadataFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&metadataFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse '%s': %w", animal.ImageMetadataPath, err)
+	}
+
+	imageFiles, err := listImageFiles(animal)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make([]string, 0)
+	images := map[string]bool{}
+	for _, imageFile := range imageFiles {
+		images[imageFile] = true
+		_, ok := metadataFile.Images[imageFile]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("'%s' has no metadata entry", imageFile))
+		}
+	}
+
+	names := make([]string, 0, len(metadataFile.Images))
+	for name := range metadataFile.Images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		metadata := metadataFile.Images[name]
+		if !images[name] {
+			problems = append(problems, fmt.Sprintf("'%s' has a metadata entry, but no image", name))
+		}
+
+		sourceUrl, err := url.Parse(metadata.Source)
+		if len(metadata.Source) == 0 {
+			problems = append(problems, fmt.Sprintf("'%s' has no source", name))
+		} else if err != nil || (sourceUrl.Scheme != "http" && sourceUrl.Scheme != "https") || len(sourceUrl.Host) == 0 {
+			problems = append(problems, fmt.Sprintf("'%s' has a source that isn't an http(s) URL: '%s'", name, metadata.Source))
+		}
+		if len(metadata.Attribution) == 0 {
+			problems = append(problems, fmt.Sprintf("'%s' has no attribution", name))
+		}
+
+		tags := metadata.Tags()
+		if len(tags) > s3TagCountMax {
+			problems = append(problems, fmt.Sprintf("'%s' has %d tags, but S3 allows at most %d", name, len(tags), s3TagCountMax))
+		}
+		for key, value := range tags {
+			if utf8.RuneCountInString(key) > s3TagKeyLengthMax {
+				problems = append(problems, fmt.Sprintf("'%s' has a tag key longer than %d characters: '%s'", name, s3TagKeyLengthMax, key))
+			}
+			if utf8.RuneCountInString(value) > s3TagValueLengthMax {
+				problems = append(problems, fmt.Sprintf(
+					"'%s' has a '%s' tag of %d characters, but S3 allows at most %d",
+					name,
+					key,
+					utf8.RuneCountInString(value),
+					s3TagValueLengthMax,
+				))
+			}
+			if !s3TagPattern.MatchString(value) {
+				problems = append(problems, fmt.Sprintf(
+					"'%s' has a '%s' tag with characters S3 doesn't allow (only letters, numbers, spaces and '_ . : / = + - @'): '%s'",
+					name,
+					key,
+					value,
+				))
+			}
+		}
+	}
 
-		byteValue, _ := io.ReadAll(metadataJson)
-		err = json.Unmarshal(byteValue, &metadata)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf(
+			"invalid image metadata in '%s':\n  - %s",
+			animal.ImageMetadataPath,
+			strings.Join(problems, "\n  - "),
+		)
+	}
+
+	return metadataFile.Images, nil
+}
+
+// ContentType returns the MIME type of the image, based on the format it was
+// decoded as rather than its file extension.
+func (details ImageDetails) ContentType() string {
+	return "image/" + details.Format
+}
+
+// ObjectName returns the content-addressed name of the image, which is used as
+// both its S3 key (under the animal's image prefix) and its resource name. An
+// image keeps the same name when it's renamed or moved, so only images whose
+// contents change are replaced.
+func (details ImageDetails) ObjectName() string {
+	return fmt.Sprintf("%s.%s", details.Sha256[:imageKeyHashLength], details.Format)
+}
+
+// listImageFiles returns the paths of the images in an animal's image folder,
+// and any of its subfolders, relative to the image folder. Hidden files and
+// folders, the metadata file, and files that aren't images are skipped.
+func listImageFiles(animal Animal) ([]string, error) {
+	imageFiles := make([]string, 0)
+	err := filepath.WalkDir(animal.ImageFolderPath, func(filePath string, entry os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if filePath == animal.ImageFolderPath {
+			return nil
+		}
+
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		extension := strings.ToLower(filepath.Ext(entry.Name()))
+		isImage := false
+		for _, imageFileExtension := range imageFileExtensions {
+			if extension == imageFileExtension {
+				isImage = true
+			}
+		}
+		if !isImage {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(animal.ImageFolderPath, filePath)
+		if err != nil {
+			return err
+		}
+		imageFiles = append(imageFiles, filepath.ToSlash(relativePath))
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	for _, file := range files {
-		// Skip the metadata file
-		if file.Name() == imageMetadataFile {
+	sort.Strings(imageFiles)
+	return imageFiles, nil
+}
+
+// Metadata returns the S3 object metadata that the details are stored as.
+func (details ImageDetails) Metadata() pulumi.StringMap {
+	return pulumi.StringMap{
+		"width":  pulumi.String(strconv.Itoa(details.Width)),
+		"height": pulumi.String(strconv.Itoa(details.Height)),
+		"format": pulumi.String(details.Format),
+		"size":   pulumi.String(strconv.FormatInt(details.Size, 10)),
+		"sha256": pulumi.String(details.Sha256),
+	}
+}
+
+// readImageDetails reads the dimensions and format from an image's header, and
+// checksums the whole file.
+func readImageDetails(filePath string) (ImageDetails, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return ImageDetails{}, err
+	}
+
+	imageConfig, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ImageDetails{}, fmt.Errorf("could not decode '%s': %w", filePath, err)
+	}
+
+	checksum := sha256.Sum256(data)
+	return ImageDetails{
+		Width:  imageConfig.Width,
+		Height: imageConfig.Height,
+		Format: format,
+		Size:   int64(len(data)),
+		Sha256: hex.EncodeToString(checksum[:]),
+	}, nil
+}
+
+// loadImageDetails reads the details of each of an animal's images. As images
+// are stored by their contents, an animal can't have two identical images. All
+// of the images that can't be decoded, or are duplicates, are reported
+// together.
+func loadImageDetails(animal Animal) (map[string]ImageDetails, error) {
+	imageFiles, err := listImageFiles(animal)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make([]string, 0)
+	imageDetails := map[string]ImageDetails{}
+	objectNames := map[string]string{}
+	for _, imageFile := range imageFiles {
+		details, err := readImageDetails(path.Join(animal.ImageFolderPath, imageFile))
+		if err != nil {
+			problems = append(problems, err.Error())
 			continue
 		}
 
-		objectTags := pulumi.ToStringMap(metadata["images"][file.Name()])
+		duplicate, ok := objectNames[details.ObjectName()]
+		if ok {
+			problems = append(problems, fmt.Sprintf("'%s' is identical to '%s'", imageFile, duplicate))
+			continue
+		}
+		objectNames[details.ObjectName()] = imageFile
+		imageDetails[imageFile] = details
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf(
+			"invalid images in '%s':\n  - %s",
+			animal.ImageFolderPath,
+			strings.Join(problems, "\n  - "),
+		)
+	}
+
+	return imageDetails, nil
+}
+
+// validateAssets loads and validates the image metadata and images of every
+// animal, so that mistakes are reported before any resources are registered.
+// The problems with every animal are reported together.
+func validateAssets() error {
+	problems := make([]string, 0)
+	for i := range animals {
+		metadata, err := loadImageMetadata(animals[i])
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		animals[i].ImageMetadata = metadata
+
+		details, err := loadImageDetails(animals[i])
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		animals[i].ImageDetails = details
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func addFolderContentsToS3(ctx *pulumi.Context, animal Animal, s3Bucket *s3.Bucket) error {
+	// Get a list of all the images in the animal's image folder
+	imageFiles, err := listImageFiles(animal)
+	if err != nil {
+		return err
+	}
+
+	manifest := ImageManifest{
+		Images: make([]ImageManifestEntry, 0, len(imageFiles)),
+	}
+
+	for _, imageFile := range imageFiles {
+		// Every image has metadata and details, as they were validated by
+		// validateAssets
+		tags := animal.ImageMetadata[imageFile].Tags()
+		details := animal.ImageDetails[imageFile]
+		objectKey := path.Join(
+			strings.TrimPrefix(animal.ImageFolderPath, parentFolderPath),
+			details.ObjectName(),
+		)
+		manifest.Images = append(manifest.Images, ImageManifestEntry{
+			Name:         imageFile,
+			Key:          objectKey,
+			Tags:         tags,
+			ImageDetails: details,
+		})
+
+		objectTags := pulumi.ToStringMap(tags)
 
 		bucketObject, err := s3.NewBucketObject(
 			ctx,
-			fmt.Sprintf("%s-s3-assets-%s", acronym, file.Name()),
+			fmt.Sprintf("%s-s3-assets-%s-%s", acronym, animal.Name, details.ObjectName()),
 			&s3.BucketObjectArgs{
-				Bucket: s3Bucket,
-				Key: pulumi.String(
-					strings.TrimPrefix(
-						animalImageFolderPath,
-						parentFolderPath,
-					) + file.Name(),
-				),
-				Source: pulumi.NewFileAsset(path.Join(animalImageFolderPath, file.Name())),
-				Tags:   objectTags,
+				Bucket:       s3Bucket,
+				Key:          pulumi.String(objectKey),
+				Source:       pulumi.NewFileAsset(path.Join(animal.ImageFolderPath, imageFile)),
+				ContentType:  pulumi.String(details.ContentType()),
+				CacheControl: pulumi.String(imageCacheControl),
+				Tags:         objectTags,
+				Metadata:     details.Metadata(),
 			},
 		)
 		if err != nil {
@@ -612,39 +1618,328 @@ func addFolderContentsToS3(ctx *pulumi.Context, directory string, s3Bucket *s3.B
 		)
 	}
 
+	// Write the manifest of the animal's images, which the images Lambda reads
+	// instead of listing the bucket.
+	manifestJson, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	manifestObject, err := s3.NewBucketObject(
+		ctx,
+		fmt.Sprintf("%s-s3-assets-%s-%s", acronym, animal.Name, imageManifestFile),
+		&s3.BucketObjectArgs{
+			Bucket: s3Bucket,
+			Key: pulumi.String(
+				strings.TrimPrefix(
+					animal.ImageManifestPath,
+					parentFolderPath,
+				),
+			),
+			Content:      pulumi.String(string(manifestJson)),
+			ContentType:  pulumi.String("application/json"),
+			CacheControl: pulumi.String(manifestCacheControl),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Add the resource to createdInfrastructure for testing purposes.
+	createdInfrastructure.S3Objects = append(
+		createdInfrastructure.S3Objects,
+		manifestObject,
+	)
+
 	return nil
 }
 
-func addTextContentsToDdb(ctx *pulumi.Context, filePath string, ddbTable *dynamodb.Table) error {
+// readLines reads a text file line-by-line.
+func readLines(filePath string) ([]string, error) {
 	// Open the text file
 	textFile, err := os.Open(filePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Ensure that the file is closed at the end of the function
 	defer textFile.Close()
 
-	// Create a scanner to read in the file line-by-line
+	lines := make([]string, 0)
 	scanner := bufio.NewScanner(textFile)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
 
-	// Init a counter for the fact ID field.
-	factId := 0
+	return lines, nil
+}
+
+// getFactTranslations reads each of the animal's translated fact files, such
+// as `facts.fr.txt`, keyed by language code. Each line is the translation of
+// the fact at the same position in `facts.txt` (or the structured fact file,
+// as long as none of its facts have ids), and may be left blank if it hasn't
+// been translated yet.
+func getFactTranslations(animal Animal, factCount int) (map[string][]string, error) {
+	files, err := os.ReadDir(animal.AssetFolderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	translations := map[string][]string{}
+	for _, file := range files {
+		matches := factTranslationFilePattern.FindStringSubmatch(file.Name())
+		if matches == nil {
+			continue
+		}
+
+		lang := matches[1]
+		if lang == defaultFactLanguage {
+			return nil, fmt.Errorf(
+				"'%s' facts in '%s' should be in 'facts.txt'",
+				defaultFactLanguage,
+				animal.AssetFolderPath,
+			)
+		}
+
+		lines, err := readLines(path.Join(animal.AssetFolderPath, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) > factCount {
+			return nil, fmt.Errorf(
+				"'%s' has %d lines, but '%s' only has %d facts",
+				file.Name(),
+				len(lines),
+				animal.Name,
+				factCount,
+			)
+		}
+
+		translations[lang] = lines
+	}
+
+	return translations, nil
+}
+
+// readFactFile reads one of the animal's structured fact files. Unknown fields
+// are rejected, so that typos don't silently drop data.
+func readFactFile(filePath string) ([]FactFileEntry, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var factFile FactFile
+	if filepath.Ext(filePath) == ".json" {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&factFile)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&factFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse '%s': %w", filePath, err)
+	}
+
+	return factFile.Facts, nil
+}
+
+// validateFactFileEntry checks a single entry of a structured fact file.
+func validateFactFileEntry(entry FactFileEntry) error {
+	if len(strings.TrimSpace(entry.Text)) == 0 {
+		return fmt.Errorf("'text' must not be empty")
+	}
+
+	if entry.Id != nil && (*entry.Id < 0 || *entry.Id >= runtimeFactIdStart) {
+		return fmt.Errorf(
+			"'id' must be between 0 and %d, got: %d",
+			runtimeFactIdStart-1,
+			*entry.Id,
+		)
+	}
+
+	if len(entry.Source) > 0 {
+		sourceUrl, err := url.Parse(entry.Source)
+		if err != nil || (sourceUrl.Scheme != "http" && sourceUrl.Scheme != "https") || len(sourceUrl.Host) == 0 {
+			return fmt.Errorf("'source' must be a http(s) URL, got: '%s'", entry.Source)
+		}
+	}
+
+	for _, tag := range entry.Tags {
+		if len(strings.TrimSpace(tag)) == 0 {
+			return fmt.Errorf("'tags' must not be empty")
+		}
+	}
+
+	for lang, text := range entry.Translations {
+		if !languageCodePattern.MatchString(lang) || lang == defaultFactLanguage {
+			return fmt.Errorf("'%s' is not a valid translation language", lang)
+		}
+		if len(strings.TrimSpace(text)) == 0 {
+			return fmt.Errorf("the '%s' translation must not be empty", lang)
+		}
+	}
+
+	return nil
+}
+
+// readFactEntries reads the animal's structured fact file if it has one, and
+// falls back to treating each line of `facts.txt` as a fact otherwise.
+func readFactEntries(animal Animal) ([]FactFileEntry, error) {
+	factFilePath := ""
+	for _, fileName := range structuredFactFiles {
+		candidatePath := path.Join(animal.AssetFolderPath, fileName)
+		_, err := os.Stat(candidatePath)
+		if err != nil {
+			continue
+		}
+		if len(factFilePath) > 0 {
+			return nil, fmt.Errorf(
+				"'%s' has both '%s' and '%s', only one is allowed",
+				animal.Name,
+				path.Base(factFilePath),
+				fileName,
+			)
+		}
+		factFilePath = candidatePath
+	}
+
+	if len(factFilePath) == 0 {
+		lines, err := readLines(animal.FactFile)
+		if err != nil {
+			return nil, err
+		}
+
+		entries := make([]FactFileEntry, 0, len(lines))
+		for _, line := range lines {
+			entries = append(entries, FactFileEntry{Text: line})
+		}
+		return entries, nil
+	}
+
+	entries, err := readFactFile(factFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	for position, entry := range entries {
+		err = validateFactFileEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"fact %d in '%s' is invalid: %w",
+				position,
+				factFilePath,
+				err,
+			)
+		}
+	}
+
+	return entries, nil
+}
+
+// loadFacts reads all of an animal's facts, assigns their FactIds, and merges
+// in any translations.
+func loadFacts(animal Animal) ([]Fact, error) {
+	entries, err := readFactEntries(animal)
+	if err != nil {
+		return nil, err
+	}
+
+	translations, err := getFactTranslations(animal, len(entries))
+	if err != nil {
+		return nil, err
+	}
+
+	// Translated fact files are matched to facts by position, which breaks
+	// as soon as facts are reordered, so they can't be used once facts have
+	// their own ids.
+	if len(translations) > 0 {
+		for _, entry := range entries {
+			if entry.Id != nil {
+				return nil, fmt.Errorf(
+					"'%s' facts have ids, so their translations must be given in the structured fact file, rather than in 'facts.<lang>.txt' files",
+					animal.Name,
+				)
+			}
+		}
+	}
+
+	facts := make([]Fact, 0, len(entries))
+	factIds := map[int]int{}
+	for position, entry := range entries {
+		factId := position
+		if entry.Id != nil {
+			factId = *entry.Id
+		}
+
+		otherPosition, found := factIds[factId]
+		if found {
+			return nil, fmt.Errorf(
+				"'%s' facts %d and %d both have the id %d, give them distinct ids",
+				animal.Name,
+				otherPosition,
+				position,
+				factId,
+			)
+		}
+		factIds[factId] = position
 
-	// For each line in the text file...
-	for scanner.Scan() {
 		fact := Fact{
-			FactId: factId,
-			Text:   scanner.Text(),
+			Animal:       animal.Name,
+			FactId:       factId,
+			Text:         entry.Text,
+			Lang:         defaultFactLanguage,
+			Source:       entry.Source,
+			Tags:         entry.Tags,
+			Translations: map[string]string{},
 		}
 
+		// Translations in the structured fact file take precedence over
+		// those in the translated fact files.
+		for lang, translatedLines := range translations {
+			if position < len(translatedLines) && len(strings.TrimSpace(translatedLines[position])) > 0 {
+				fact.Translations[lang] = translatedLines[position]
+			}
+		}
+		for lang, text := range entry.Translations {
+			fact.Translations[lang] = text
+		}
+
+		facts = append(facts, fact)
+	}
+
+	return facts, nil
+}
+
+func addTextContentsToDdb(ctx *pulumi.Context, animal Animal, ddbTable *dynamodb.Table) error {
+	// Read in the animal's facts, and any translations of them
+	facts, err := loadFacts(animal)
+	if err != nil {
+		return err
+	}
+
+	factIds := make([]int, 0, len(facts))
+	seedVersion := sha256.New()
+	for _, fact := range facts {
+		item, err := fact.MarshalToDynamoDB()
+		if err != nil {
+			return err
+		}
+		seedVersion.Write([]byte(item))
+
 		tableItem, err := dynamodb.NewTableItem(
 			ctx,
-			fmt.Sprintf("%s-ddb-facts-%d", acronym, factId),
+			fmt.Sprintf("%s-ddb-facts-%s-%d", acronym, animal.Name, fact.FactId),
 			&dynamodb.TableItemArgs{
 				TableName: ddbTable.Name,
 				HashKey:   ddbTable.HashKey,
-				Item:      pulumi.String(fact.MarshalToDynamoDB()),
+				RangeKey:  ddbTable.RangeKey,
+				Item:      pulumi.String(item),
 			},
 		)
 		if err != nil {
@@ -655,21 +1950,310 @@ func addTextContentsToDdb(ctx *pulumi.Context, filePath string, ddbTable *dynamo
 			createdInfrastructure.DdbTableItems,
 			tableItem,
 		)
-		factId++
+		factIds = append(factIds, fact.FactId)
 	}
-	if err := scanner.Err(); err != nil {
+	sort.Ints(factIds)
+
+	// Record which facts were seeded. Facts created at runtime are tracked
+	// separately by the facts Lambda, so this won't clobber them. Seeded facts
+	// deleted at runtime are forgotten whenever the seeded facts change, as
+	// they may have just been seeded again.
+	factIndex := FactIndex{
+		Animal:      animal.Name,
+		FactId:      seedIndexFactId,
+		FactCount:   len(factIds),
+		FactIds:     factIds,
+		SeedVersion: hex.EncodeToString(seedVersion.Sum(nil)),
+	}
+
+	item, err := factIndex.MarshalToDynamoDB()
+	if err != nil {
+		return err
+	}
+
+	tableItem, err := dynamodb.NewTableItem(
+		ctx,
+		fmt.Sprintf("%s-ddb-facts-%s-index", acronym, animal.Name),
+		&dynamodb.TableItemArgs{
+			TableName: ddbTable.Name,
+			HashKey:   ddbTable.HashKey,
+			RangeKey:  ddbTable.RangeKey,
+			Item:      pulumi.String(item),
+		},
+	)
+	if err != nil {
 		return err
 	}
+	// Add the resource to createdInfrastructure for testing purposes.
+	createdInfrastructure.DdbTableItems = append(
+		createdInfrastructure.DdbTableItems,
+		tableItem,
+	)
 
 	return nil
 }
 
+// deployCdn creates a CloudFront distribution in front of the REST API and the
+// assets bucket. The bucket is read through an Origin Access Control, so it's
+// reachable through the distribution even when public access is blocked.
+func deployCdn(
+	ctx *pulumi.Context,
+	api *apigateway.RestAPI,
+	bucket *s3.Bucket,
+) (*cloudfront.Distribution, error) {
+	s3OriginId := fmt.Sprintf("%s-s3-assets", acronym)
+	apiOriginId := fmt.Sprintf("%s-apigw", acronym)
+
+	originAccessControl, err := cloudfront.NewOriginAccessControl(
+		ctx,
+		fmt.Sprintf("%s-cdn-oac", acronym),
+		&cloudfront.OriginAccessControlArgs{
+			Name:                          pulumi.Sprintf("%s-cdn-oac", acronym),
+			OriginAccessControlOriginType: pulumi.String("s3"),
+			SigningBehavior:               pulumi.String("always"),
+			SigningProtocol:               pulumi.String("sigv4"),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The REST API's URL is of the form `https://<domain>/<stage>/`, and
+	// CloudFront needs the two parts separately.
+	apiDomain := api.Url.ApplyT(func(apiUrl string) (string, error) {
+		u, err := url.Parse(apiUrl)
+		if err != nil {
+			return "", err
+		}
+		return u.Host, nil
+	}).(pulumi.StringOutput)
+	apiStage := api.Url.ApplyT(func(apiUrl string) (string, error) {
+		u, err := url.Parse(apiUrl)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(u.Path, "/"), nil
+	}).(pulumi.StringOutput)
+
+	apiForwardedValues := &cloudfront.DistributionOrderedCacheBehaviorForwardedValuesArgs{
+		Cookies: &cloudfront.DistributionOrderedCacheBehaviorForwardedValuesCookiesArgs{
+			Forward: pulumi.String("none"),
+		},
+		Headers:     pulumi.ToStringArray(cdnForwardedHeaders),
+		QueryString: pulumi.Bool(true),
+	}
+	allMethods := pulumi.ToStringArray([]string{
+		"DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT",
+	})
+	readMethods := pulumi.ToStringArray([]string{"GET", "HEAD"})
+
+	distribution, err := cloudfront.NewDistribution(
+		ctx,
+		fmt.Sprintf("%s-cdn", acronym),
+		&cloudfront.DistributionArgs{
+			Enabled:    pulumi.Bool(true),
+			PriceClass: pulumi.String("PriceClass_100"),
+			Origins: cloudfront.DistributionOriginArray{
+				&cloudfront.DistributionOriginArgs{
+					OriginId:              pulumi.String(s3OriginId),
+					DomainName:            bucket.BucketRegionalDomainName,
+					OriginAccessControlId: originAccessControl.ID(),
+				},
+				&cloudfront.DistributionOriginArgs{
+					OriginId:   pulumi.String(apiOriginId),
+					DomainName: apiDomain,
+					OriginPath: apiStage,
+					CustomOriginConfig: &cloudfront.DistributionOriginCustomOriginConfigArgs{
+						HttpPort:             pulumi.Int(80),
+						HttpsPort:            pulumi.Int(443),
+						OriginProtocolPolicy: pulumi.String("https-only"),
+						OriginSslProtocols:   pulumi.ToStringArray([]string{"TLSv1.2"}),
+					},
+				},
+			},
+			OrderedCacheBehaviors: cloudfront.DistributionOrderedCacheBehaviorArray{
+				// The images in the assets bucket, and the images that the
+				// images Lambda has resized. Their keys change with their
+				// contents, so they're cached for as long as possible.
+				&cloudfront.DistributionOrderedCacheBehaviorArgs{
+					PathPattern:          pulumi.String("/assets/*"),
+					TargetOriginId:       pulumi.String(s3OriginId),
+					ViewerProtocolPolicy: pulumi.String("redirect-to-https"),
+					AllowedMethods:       readMethods,
+					CachedMethods:        readMethods,
+					Compress:             pulumi.Bool(true),
+					MinTtl:               pulumi.IntPtr(0),
+					DefaultTtl:           pulumi.IntPtr(cdnImagesTtlDefault),
+					MaxTtl:               pulumi.IntPtr(cdnImagesTtlMax),
+					ForwardedValues: &cloudfront.DistributionOrderedCacheBehaviorForwardedValuesArgs{
+						Cookies: &cloudfront.DistributionOrderedCacheBehaviorForwardedValuesCookiesArgs{
+							Forward: pulumi.String("none"),
+						},
+						QueryString: pulumi.Bool(false),
+					},
+				},
+				&cloudfront.DistributionOrderedCacheBehaviorArgs{
+					PathPattern:          pulumi.Sprintf("/%s/*", derivedImagePrefix),
+					TargetOriginId:       pulumi.String(s3OriginId),
+					ViewerProtocolPolicy: pulumi.String("redirect-to-https"),
+					AllowedMethods:       readMethods,
+					CachedMethods:        readMethods,
+					Compress:             pulumi.Bool(true),
+					MinTtl:               pulumi.IntPtr(0),
+					DefaultTtl:           pulumi.IntPtr(cdnImagesTtlDefault),
+					MaxTtl:               pulumi.IntPtr(cdnImagesTtlMax),
+					ForwardedValues: &cloudfront.DistributionOrderedCacheBehaviorForwardedValuesArgs{
+						Cookies: &cloudfront.DistributionOrderedCacheBehaviorForwardedValuesCookiesArgs{
+							Forward: pulumi.String("none"),
+						},
+						QueryString: pulumi.Bool(false),
+					},
+				},
+				// The images resized by the images Lambda, by name
+				&cloudfront.DistributionOrderedCacheBehaviorArgs{
+					PathPattern:          pulumi.String("/*/images/*"),
+					TargetOriginId:       pulumi.String(apiOriginId),
+					ViewerProtocolPolicy: pulumi.String("redirect-to-https"),
+					AllowedMethods:       readMethods,
+					CachedMethods:        readMethods,
+					Compress:             pulumi.Bool(true),
+					MinTtl:               pulumi.IntPtr(0),
+					DefaultTtl:           pulumi.IntPtr(cdnImagesTtlDefault),
+					MaxTtl:               pulumi.IntPtr(cdnImagesTtlMax),
+					ForwardedValues:      apiForwardedValues,
+				},
+				// Facts can be changed through the API, so they're only
+				// cached briefly.
+				&cloudfront.DistributionOrderedCacheBehaviorArgs{
+					PathPattern:          pulumi.String("/*/facts*"),
+					TargetOriginId:       pulumi.String(apiOriginId),
+					ViewerProtocolPolicy: pulumi.String("redirect-to-https"),
+					AllowedMethods:       allMethods,
+					CachedMethods:        readMethods,
+					Compress:             pulumi.Bool(true),
+					MinTtl:               pulumi.IntPtr(0),
+					DefaultTtl:           pulumi.IntPtr(cdnFactsTtlDefault),
+					MaxTtl:               pulumi.IntPtr(cdnFactsTtlMax),
+					ForwardedValues:      apiForwardedValues,
+				},
+				// PATs are never cached
+				&cloudfront.DistributionOrderedCacheBehaviorArgs{
+					PathPattern:          pulumi.String("/pats*"),
+					TargetOriginId:       pulumi.String(apiOriginId),
+					ViewerProtocolPolicy: pulumi.String("redirect-to-https"),
+					AllowedMethods:       allMethods,
+					CachedMethods:        readMethods,
+					MinTtl:               pulumi.IntPtr(0),
+					DefaultTtl:           pulumi.IntPtr(0),
+					MaxTtl:               pulumi.IntPtr(0),
+					ForwardedValues:      apiForwardedValues,
+				},
+			},
+			// Everything else, including random images, is never cached
+			DefaultCacheBehavior: &cloudfront.DistributionDefaultCacheBehaviorArgs{
+				TargetOriginId:       pulumi.String(apiOriginId),
+				ViewerProtocolPolicy: pulumi.String("redirect-to-https"),
+				AllowedMethods:       allMethods,
+				CachedMethods:        readMethods,
+				MinTtl:               pulumi.IntPtr(0),
+				DefaultTtl:           pulumi.IntPtr(0),
+				MaxTtl:               pulumi.IntPtr(0),
+				ForwardedValues: &cloudfront.DistributionDefaultCacheBehaviorForwardedValuesArgs{
+					Cookies: &cloudfront.DistributionDefaultCacheBehaviorForwardedValuesCookiesArgs{
+						Forward: pulumi.String("none"),
+					},
+					Headers:     pulumi.ToStringArray(cdnForwardedHeaders),
+					QueryString: pulumi.Bool(true),
+				},
+			},
+			Restrictions: &cloudfront.DistributionRestrictionsArgs{
+				GeoRestriction: &cloudfront.DistributionRestrictionsGeoRestrictionArgs{
+					RestrictionType: pulumi.String("none"),
+				},
+			},
+			ViewerCertificate: &cloudfront.DistributionViewerCertificateArgs{
+				CloudfrontDefaultCertificate: pulumi.Bool(true),
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add the resource to createdInfrastructure for testing purposes.
+	createdInfrastructure.Distributions = append(
+		createdInfrastructure.Distributions,
+		distribution,
+	)
+
+	// A public bucket can already be read by the distribution. A private one
+	// needs a policy that lets CloudFront read it on the distribution's behalf.
+	if assetBucketAccess == assetBucketAccessPrivate {
+		_, err = s3.NewBucketPolicy(
+			ctx,
+			fmt.Sprintf("%s-assets-cdn-policy", acronym),
+			&s3.BucketPolicyArgs{
+				Bucket: bucket.ID(),
+				Policy: pulumi.Any(map[string]interface{}{
+					"Version": "2012-10-17",
+					"Statement": []map[string]interface{}{
+						{
+							"Effect": "Allow",
+							"Principal": map[string]interface{}{
+								"Service": "cloudfront.amazonaws.com",
+							},
+							"Action": []interface{}{
+								"s3:GetObject",
+							},
+							"Resource": []interface{}{
+								pulumi.Sprintf("%s/*", bucket.Arn),
+							},
+							"Condition": map[string]interface{}{
+								"StringEquals": map[string]interface{}{
+									"AWS:SourceArn": distribution.Arn,
+								},
+							},
+						},
+					},
+				}),
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return distribution, nil
+}
+
 func createInfrastructure(ctx *pulumi.Context) (*Infrastructure, error) {
 	// Initialise paths and naming strings
-	initStrings(ctx)
+	err := initStrings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the assets before any resources are registered
+	err = validateAssets()
+	if err != nil {
+		return nil, err
+	}
 
 	// Compile the Lambda functions
-	err := compileLambdas()
+	err = compileLambdas()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the PAT table, which is shared by several Lambda functions
+	err = deployPatTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the Lambda function that checks PATs, which the routes of the
+	// other Lambda functions refer to
+	err = deployPatAuthorizer(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -692,7 +2276,10 @@ func createInfrastructure(ctx *pulumi.Context) (*Infrastructure, error) {
 	}
 
 	// Create the API Gateway resource to route requests to the Lambda
-	// functions depending on defined paths
+	// functions depending on defined paths. The RestAPI component registers
+	// `*/*` as a binary media type, so the base64-encoded images returned by
+	// the images Lambda are decoded before they're sent to the client. This
+	// version of the SDK doesn't expose the setting, so it can't be narrowed.
 	api, err := apigateway.NewRestAPI(
 		ctx,
 		fmt.Sprintf("%s-apigw", acronym),
@@ -713,6 +2300,43 @@ func createInfrastructure(ctx *pulumi.Context) (*Infrastructure, error) {
 	// The URL at which the REST API will be served
 	ctx.Export("url", api.Url)
 
+	// Put the REST API and the assets bucket behind a CloudFront distribution
+	if cdnEnabled {
+		distribution, err := deployCdn(ctx, api, assetBucket)
+		if err != nil {
+			return nil, err
+		}
+
+		// The domain at which the REST API and images will be served through
+		// the CloudFront distribution
+		ctx.Export("cdnDomain", distribution.DomainName)
+
+		// The distribution depends on the Lambdas, through the REST API, so
+		// they read its domain name from a parameter instead of their
+		// environment.
+		domain := distribution.DomainName
+		if len(cdnDomain) > 0 {
+			domain = pulumi.String(cdnDomain).ToStringOutput()
+		}
+		parameter, err := ssm.NewParameter(
+			ctx,
+			fmt.Sprintf("%s-cdn-domain", acronym),
+			&ssm.ParameterArgs{
+				Name:  pulumi.String(getCdnDomainParameterName()),
+				Type:  pulumi.String("String"),
+				Value: domain,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		// Add the resource to createdInfrastructure for testing purposes.
+		createdInfrastructure.SsmParameters = append(
+			createdInfrastructure.SsmParameters,
+			parameter,
+		)
+	}
+
 	return &createdInfrastructure, nil
 }
 
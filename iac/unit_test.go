@@ -4,8 +4,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"strings"
 	"sync"
 	"testing"
@@ -31,7 +33,7 @@ func (mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
 // Applying unit tests.
 func TestInfrastructure(t *testing.T) {
 	// This is required because the tests do not properly ingest the Pulumi config file.
-	os.Setenv("PULUMI_CONFIG", "{ \"project:animal\": \"platypus\" }")
+	os.Setenv("PULUMI_CONFIG", "{ \"project:animals\": \"otter,platypus\" }")
 	
 	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
 		fmt.Printf("Executing ~UNIT~ tests...\n")
@@ -197,3 +199,169 @@ func TestInfrastructure(t *testing.T) {
 	}, pulumi.WithMocks("project", "stack", mocks(0)))
 	assert.NoError(t, err)
 }
+
+func TestLoadFacts(t *testing.T) {
+	fmt.Printf("Executing ~UNIT~ fact loading tests...\n")
+	animalFolder := t.TempDir()
+	animal := Animal{
+		Name:            "otter",
+		AssetFolderPath: animalFolder,
+		FactFile:        path.Join(animalFolder, "facts.txt"),
+	}
+
+	// Plain `facts.txt` files are numbered by line.
+	err := os.WriteFile(animal.FactFile, []byte("First fact\nSecond \"quoted\" fact\n"), 0644)
+	assert.NoError(t, err)
+
+	facts, err := loadFacts(animal)
+	assert.NoError(t, err)
+	assert.Len(t, facts, 2)
+	assert.Equal(t, 1, facts[1].FactId)
+	assert.Equal(t, "Second \"quoted\" fact", facts[1].Text)
+
+	// Quotes must survive being marshalled for DynamoDB.
+	item, err := facts[1].MarshalToDynamoDB()
+	assert.NoError(t, err)
+	var decodedItem map[string]map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(item), &decodedItem))
+	assert.Equal(t, "Second \"quoted\" fact", decodedItem["Text"]["S"])
+
+	// Structured fact files take precedence over `facts.txt`.
+	err = os.WriteFile(path.Join(animalFolder, "facts.yaml"), []byte(`facts:
+  - id: 7
+    text: Otters hold hands while they sleep.
+    source: https://example.com/otters
+    tags: [behaviour]
+    translations:
+      fr: Les loutres se tiennent la main pendant qu'elles dorment.
+  - text: Otters have pockets.
+`), 0644)
+	assert.NoError(t, err)
+
+	facts, err = loadFacts(animal)
+	assert.NoError(t, err)
+	assert.Len(t, facts, 2)
+	assert.Equal(t, 7, facts[0].FactId)
+	assert.Equal(t, "https://example.com/otters", facts[0].Source)
+	assert.Equal(t, []string{"behaviour"}, facts[0].Tags)
+	assert.Contains(t, facts[0].Translations, "fr")
+	assert.Equal(t, 1, facts[1].FactId)
+
+	// Positional translations can't be matched to facts with ids.
+	err = os.WriteFile(path.Join(animalFolder, "facts.fr.txt"), []byte("Premier fait\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = loadFacts(animal)
+	assert.Error(t, err)
+	assert.NoError(t, os.Remove(path.Join(animalFolder, "facts.fr.txt")))
+
+	// Duplicate ids are rejected.
+	err = os.WriteFile(path.Join(animalFolder, "facts.yaml"), []byte(`facts:
+  - id: 1
+    text: One.
+  - text: Also one.
+`), 0644)
+	assert.NoError(t, err)
+
+	_, err = loadFacts(animal)
+	assert.Error(t, err)
+	fmt.Printf("\tCOMPLETE\n")
+}
+
+func TestLoadImageMetadata(t *testing.T) {
+	fmt.Printf("Executing ~UNIT~ image metadata tests...\n")
+	imageFolder := t.TempDir()
+	animal := Animal{
+		Name:              "otter",
+		ImageFolderPath:   imageFolder,
+		ImageMetadataPath: path.Join(imageFolder, "metadata.json"),
+	}
+	imageMetadataFile = "metadata.json"
+
+	err := os.WriteFile(path.Join(imageFolder, "otters01.png"), []byte{}, 0644)
+	assert.NoError(t, err)
+	err = os.WriteFile(path.Join(imageFolder, "otters02.png"), []byte{}, 0644)
+	assert.NoError(t, err)
+
+	// Valid metadata is turned into tags.
+	err = os.WriteFile(animal.ImageMetadataPath, []byte(`{"images": {
+		"otters01.png": {"source": "https://example.com/1", "attribution": "Someone"},
+		"otters02.png": {"source": "https://example.com/2", "attribution": "Someone", "description": "Otters"}
+	}}`), 0644)
+	assert.NoError(t, err)
+
+	metadata, err := loadImageMetadata(animal)
+	assert.NoError(t, err)
+	assert.Len(t, metadata["otters01.png"].Tags(), 2)
+	assert.Equal(t, "Otters", metadata["otters02.png"].Tags()["description"])
+
+	// Every problem is reported together.
+	err = os.WriteFile(animal.ImageMetadataPath, []byte(`{"images": {
+		"otters01.png": {"source": "not a url", "attribution": "`+strings.Repeat("a", 257)+`"},
+		"otters03.png": {"source": "https://example.com/3", "attribution": "Someone"}
+	}}`), 0644)
+	assert.NoError(t, err)
+
+	_, err = loadImageMetadata(animal)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "'otters01.png' has a source that isn't an http(s) URL")
+	assert.Contains(t, err.Error(), "'otters01.png' has a 'attribution' tag of 257 characters")
+	assert.Contains(t, err.Error(), "'otters02.png' has no metadata entry")
+	assert.Contains(t, err.Error(), "'otters03.png' has a metadata entry, but no image")
+
+	// Unknown fields are rejected.
+	err = os.WriteFile(animal.ImageMetadataPath, []byte(`{"images": {
+		"otters01.png": {"source": "https://example.com/1", "attribution": "Someone", "description:": "Typo"}
+	}}`), 0644)
+	assert.NoError(t, err)
+
+	_, err = loadImageMetadata(animal)
+	assert.Error(t, err)
+	fmt.Printf("\tCOMPLETE\n")
+}
+
+func TestReadImageDetails(t *testing.T) {
+	fmt.Printf("Executing ~UNIT~ image details tests...\n")
+	details, err := readImageDetails("../assets/animals/otter/images/otters01.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "png", details.Format)
+	assert.Greater(t, details.Width, 0)
+	assert.Greater(t, details.Height, 0)
+	assert.Greater(t, details.Size, int64(0))
+	assert.Len(t, details.Sha256, 64)
+
+	// Files that aren't images are rejected.
+	notAnImage := path.Join(t.TempDir(), "notes.png")
+	assert.NoError(t, os.WriteFile(notAnImage, []byte("not an image"), 0644))
+	_, err = readImageDetails(notAnImage)
+	assert.Error(t, err)
+	fmt.Printf("\tCOMPLETE\n")
+}
+
+func TestListImageFiles(t *testing.T) {
+	fmt.Printf("Executing ~UNIT~ image listing tests...\n")
+	imageFolder := t.TempDir()
+	animal := Animal{
+		Name:            "otter",
+		ImageFolderPath: imageFolder,
+	}
+
+	assert.NoError(t, os.MkdirAll(path.Join(imageFolder, "pups"), 0755))
+	assert.NoError(t, os.MkdirAll(path.Join(imageFolder, ".cache"), 0755))
+	for _, fileName := range []string{
+		"otters01.png",
+		"pups/otters02.JPG",
+		".cache/otters03.png",
+		".DS_Store",
+		"metadata.json",
+		"notes.txt",
+	} {
+		assert.NoError(t, os.WriteFile(path.Join(imageFolder, fileName), []byte{}, 0644))
+	}
+
+	// Hidden files and folders, and files that aren't images, are skipped.
+	imageFiles, err := listImageFiles(animal)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"otters01.png", "pups/otters02.JPG"}, imageFiles)
+	fmt.Printf("\tCOMPLETE\n")
+}